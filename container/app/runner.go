@@ -1,20 +1,146 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
 	"io/ioutil"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/cloudfoundry/app_container_setup/container"
 )
 
+const (
+	exitCodeFailure     = 1
+	exitCodeInputError  = 2
+	exitCodeWardenError = 3
+	exitCodeTaskFailed  = 4
+	exitCodeInterrupted = 5
+	exitCodeTimedOut    = 6
+)
+
+var (
+	strict       = flag.Bool("strict", false, "reject unknown keys in the input JSON")
+	stream       = flag.Bool("stream", false, "relay the task's stdout and stderr while it runs")
+	stdoutPrefix = flag.String("stdout-prefix", "", "prefix for each line of streamed task stdout")
+	stderrPrefix = flag.String("stderr-prefix", "", "prefix for each line of streamed task stderr")
+	destroy      = flag.Bool("destroy", false, "destroy the container once all steps have completed")
+	timeout      = flag.Duration("timeout", 0, "overall deadline for setting up the container and running the task, 0 for none")
+	gracePeriod  = flag.Duration("grace-period", 10*time.Second, "time to let the task stop after SIGINT or SIGTERM before destroying the container")
+
+	taskStdout *container.LineTrackingWriter
+)
+
 func main() {
+	flag.Parse()
+
 	inputJson, err := ioutil.ReadAll(os.Stdin)
 	if err != nil {
-		panic("failed to read")
+		result := container.NewSetupResult()
+		result.SetError(&container.InputError{Err: err})
+		exit(result, exitCodeInputError)
+	}
+
+	ctx := context.Background()
+	if *timeout != 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	if flag.Arg(0) == "status" {
+		status(ctx, string(inputJson))
+	}
+
+	result := container.NewSetupResult()
+	options := &container.Options{Strict: *strict, GracePeriod: *gracePeriod}
+	if *stream {
+		taskStdout = container.NewLineTrackingWriter(os.Stdout)
+		options.TaskStdout = container.NewLinePrefixWriter(taskStdout, *stdoutPrefix)
+		options.TaskStderr = container.NewLinePrefixWriter(os.Stderr, *stderrPrefix)
+	}
+
+	state, err := container.Setup(string(inputJson), options)
+	if err != nil {
+		result.SetError(err)
+		exit(result, exitCodeFor(err))
+	}
+	result = state.Result
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	performed := make(chan error, 1)
+	go func() {
+		performed <- state.Perform(ctx)
+	}()
+
+	select {
+	case err = <-performed:
+	case sig := <-signals:
+		err = state.Teardown(cancel, performed)
+		if err != nil {
+			result.SetError(fmt.Errorf("interrupted by %s, destroying container failed: %s", sig, err))
+		} else {
+			result.SetError(fmt.Errorf("interrupted by %s", sig))
+		}
+		exit(result, exitCodeInterrupted)
+	}
+	if err == nil && *destroy {
+		destroyCtx, cancelDestroy := context.WithTimeout(context.Background(), state.CleanupTimeout)
+		if destroyErr := state.Container.Destroy(destroyCtx); destroyErr != nil {
+			err = &container.StepError{Step: "destroy", Err: destroyErr}
+		}
+		cancelDestroy()
+	}
+	result.SetError(err)
+
+	if err == nil && result.Task.Failed() {
+		exit(result, exitCodeTaskFailed)
+	}
+	exit(result, exitCodeFor(err))
+}
+
+func status(ctx context.Context, inputJson string) {
+	info, err := container.Status(ctx, inputJson, &container.Options{Strict: *strict})
+	if err != nil {
+		exit(map[string]string{"error": err.Error()}, exitCodeFor(err))
+	}
+	exit(info, 0)
+}
+
+func exitCodeFor(err error) int {
+	switch err := err.(type) {
+	case nil:
+		return 0
+	case *container.InputError:
+		return exitCodeInputError
+	case *container.StepError:
+		if _, timedOut := err.Err.(*container.TaskTimeoutError); timedOut || err.Err == context.DeadlineExceeded {
+			return exitCodeTimedOut
+		}
+		return exitCodeWardenError
+	default:
+		return exitCodeFailure
 	}
+}
 
-	err = container.Main(inputJson)
+func exit(result interface{}, code int) {
+	output, err := json.Marshal(result)
 	if err != nil {
-		panic("Main failed")
+		fmt.Fprintf(os.Stderr, "failed to encode result: %s\n", err)
+		os.Exit(exitCodeFailure)
+	}
+	if taskStdout != nil {
+		taskStdout.FinishLine()
 	}
+	fmt.Println(string(output))
+	os.Exit(code)
 }
@@ -0,0 +1,44 @@
+package container
+
+import (
+	"sort"
+)
+
+const DefaultBackend = "warden"
+
+type Backend func(commandLineJson *CommandLineJson) (ContainerCreator, error)
+
+var backends = map[string]Backend{
+	"warden": newWardenContainer,
+	"local":  newLocalContainer,
+}
+
+func RegisterBackend(name string, backend Backend) {
+	backends[name] = backend
+}
+
+func Backends() []string {
+	var names []string
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (c *CommandLineJson) backend() string {
+	if c.Backend == "" {
+		return DefaultBackend
+	}
+	return c.Backend
+}
+
+func newWardenContainer(commandLineJson *CommandLineJson) (ContainerCreator, error) {
+	container := NewContainer(NewWardenConnection(commandLineJson.WardenSocketPath))
+	container.SetRetryPolicy(commandLineJson.WardenRetries.Policy())
+	return container, nil
+}
+
+func newLocalContainer(commandLineJson *CommandLineJson) (ContainerCreator, error) {
+	return NewLocalContainer(commandLineJson.LocalRoot), nil
+}
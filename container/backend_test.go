@@ -0,0 +1,52 @@
+package container
+
+import (
+	. "launchpad.net/gocheck"
+)
+
+type BackendSuite struct {
+}
+
+func init() {
+	Suite(&BackendSuite{})
+}
+
+func (s *BackendSuite) TestSetupDefaultsToWarden(c *C) {
+	state, err := Setup(`{
+	"warden_socket_path": "/tmp/warden.sock",
+	"disk_limit_in_bytes": 100,
+	"memory_limit_in_bytes": 200
+}`, nil)
+
+	c.Assert(err, IsNil)
+	_, ok := state.Container.(*Container)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *BackendSuite) TestSetupUsesTheSelectedBackend(c *C) {
+	state, err := Setup(`{
+	"backend": "local",
+	"local_root": "/tmp",
+	"disk_limit_in_bytes": 100,
+	"memory_limit_in_bytes": 200
+}`, nil)
+
+	c.Assert(err, IsNil)
+	container, ok := state.Container.(*LocalContainer)
+	c.Assert(ok, Equals, true)
+	c.Assert(container.root, Equals, "/tmp")
+}
+
+func (s *BackendSuite) TestRegisterBackend(c *C) {
+	fakeContainer := &FakeContainer{}
+	RegisterBackend("fake", func(*CommandLineJson) (ContainerCreator, error) {
+		return fakeContainer, nil
+	})
+	defer delete(backends, "fake")
+
+	c.Assert(Backends(), DeepEquals, []string{"fake", "local", "warden"})
+	state, err := Setup(`{"backend": "fake", "disk_limit_in_bytes": 100, "memory_limit_in_bytes": 200}`, nil)
+
+	c.Assert(err, IsNil)
+	c.Assert(state.Container, Equals, fakeContainer)
+}
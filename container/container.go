@@ -1,72 +1,472 @@
 package container
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	warden "github.com/cloudfoundry/gordon"
+	"io"
+	"strings"
 )
 
+const (
+	vcapUser          = "vcap"
+	homeDirectoryPath = "/home/vcap"
+	outOfMemoryEvent  = "out of memory"
+)
+
+type WardenClient interface {
+	Connect() error
+	CreateByRequest(*warden.CreateRequest) (*warden.CreateResponse, error)
+	Destroy(handle string) (*warden.DestroyResponse, error)
+	Stop(handle string, background, kill bool) (*warden.StopResponse, error)
+	Info(handle string) (*warden.InfoResponse, error)
+	ListByRequest(*warden.ListRequest) (*warden.ListResponse, error)
+	LimitDiskByRequest(*warden.LimitDiskRequest) (*warden.LimitDiskResponse, error)
+	LimitMemory(handle string, limitInBytes uint64) (*warden.LimitMemoryResponse, error)
+	LimitCpu(handle string, limitInShares uint64) (*warden.LimitCpuResponse, error)
+	LimitBandwidth(handle string, rate, burst uint64) (*warden.LimitBandwidthResponse, error)
+	NetIn(handle string) (*warden.NetInResponse, error)
+	NetOutByRequest(*warden.NetOutRequest) (*warden.NetOutResponse, error)
+	RunByRequest(*warden.RunRequest) (*warden.RunResponse, error)
+	SpawnByRequest(*warden.SpawnRequest) (*warden.SpawnResponse, error)
+	Link(handle string, jobId uint32) (*warden.LinkResponse, error)
+	Stream(handle string, jobId uint32) (chan *warden.StreamResponse, error)
+	CopyIn(handle, srcPath, dstPath string) (*warden.CopyInResponse, error)
+	CopyOut(handle, srcPath, dstPath, owner string) (*warden.CopyOutResponse, error)
+}
+
 type Container struct {
-	client warden.ConnectedWardenClient
-	handle string
+	client      WardenClient
+	handle      string
+	rlimits     *warden.ResourceLimits
+	retryPolicy RetryPolicy
 }
 
 type ContainerCreator interface {
-	Create([]*BindMount) error
-	SetDiskLimit(limitInBytes uint64) error
-	SetMemoryLimit(limitInBytes uint64) error
+	Connect(ctx context.Context) error
+	Create(ctx context.Context, spec *ContainerSpec) error
+	Attach(ctx context.Context, handle string) error
+	AllowEgress(ctx context.Context, rules []*EgressRule) error
+	SetDiskQuota(ctx context.Context, quota DiskQuota) (*DiskQuota, error)
+	SetMemoryLimit(ctx context.Context, limitInBytes uint64) (uint64, error)
+	SetFileDescriptorLimit(limit uint64) error
+	SetCpuLimit(ctx context.Context, limitInShares uint64) error
+	SetBandwidthLimit(ctx context.Context, rateInBytesPerSecond, burstInBytes uint64) error
+	ConfigureApplicationPorts(ctx context.Context) (*PortMapping, error)
+	ConfigureConsolePorts(ctx context.Context) (*PortMapping, error)
+	ConfigureDebugPorts(ctx context.Context) (*PortMapping, error)
+	ConfigureHomeDirectory(ctx context.Context) error
+	InstallDroplet(ctx context.Context, path string) error
+	SpawnTask(ctx context.Context, environmentScript string, command string) (uint32, error)
+	LinkTask(ctx context.Context, jobId uint32) (uint32, error)
+	StreamTask(ctx context.Context, jobId uint32, stdout, stderr io.Writer) (uint32, error)
+	CopyOut(ctx context.Context, srcPath, dstPath string) error
+	OutOfMemory(ctx context.Context) (bool, error)
+	Stop(ctx context.Context) error
+	Kill(ctx context.Context) error
+	Destroy(ctx context.Context) error
+	Handle() string
+}
+
+type ContainerSpec struct {
+	BindMounts         []*BindMount
+	Properties         map[string]string
+	GraceTimeInSeconds uint32
 }
 
 type BindMount struct {
 	SrcPath string `json:"src_path"`
 	DstPath string `json:"dst_path"`
 	Mode    string `json:"mode"`
+	Origin  string `json:"origin"`
+}
+
+func (b *BindMount) toRequest() (*warden.CreateRequest_BindMount, error) {
+	mode, err := b.wardenMode()
+	if err != nil {
+		return nil, err
+	}
+	origin, err := b.wardenOrigin()
+	if err != nil {
+		return nil, err
+	}
+	return &warden.CreateRequest_BindMount{
+		SrcPath: &b.SrcPath,
+		DstPath: &b.DstPath,
+		Mode:    &mode,
+		Origin:  &origin,
+	}, nil
+}
+
+func (b *BindMount) wardenMode() (warden.CreateRequest_BindMount_Mode, error) {
+	switch strings.ToUpper(b.Mode) {
+	case "", "RO":
+		return warden.CreateRequest_BindMount_RO, nil
+	case "RW":
+		return warden.CreateRequest_BindMount_RW, nil
+	}
+	return 0, fmt.Errorf("invalid mode %q for bind mount %s, expected RO or RW", b.Mode, b.DstPath)
 }
 
-func NewContainer(client warden.ConnectedWardenClient) *Container {
+func (b *BindMount) wardenOrigin() (warden.CreateRequest_BindMount_Origin, error) {
+	switch strings.ToLower(b.Origin) {
+	case "", "host":
+		return warden.CreateRequest_BindMount_Host, nil
+	case "container":
+		return warden.CreateRequest_BindMount_Container, nil
+	}
+	return 0, fmt.Errorf("invalid origin %q for bind mount %s, expected host or container", b.Origin, b.DstPath)
+}
+
+type DiskQuota struct {
+	ByteSoft  uint64
+	ByteHard  uint64
+	InodeSoft uint64
+	InodeHard uint64
+}
+
+type PortMapping struct {
+	HostPort      uint32 `json:"host_port"`
+	ContainerPort uint32 `json:"container_port"`
+}
+
+func NewContainer(client WardenClient) *Container {
 	return &Container{client: client}
 }
 
-func (c *Container) Create(pathsToBind []*BindMount) error {
-	var bindMountRequests []*warden.CreateRequest_BindMount
-	ro := warden.CreateRequest_BindMount_RO
-	for _, bindMount := range pathsToBind {
-		bindMountRequests = append(bindMountRequests,
-			&warden.CreateRequest_BindMount{
-				SrcPath: &bindMount.SrcPath,
-				DstPath: &bindMount.DstPath,
-				Mode:    &ro,
-			})
-	}
-	request := &warden.CreateRequest{BindMounts: bindMountRequests}
-	response, err := c.client.CreateByRequest(request)
+func AttachContainer(ctx context.Context, client WardenClient, handle string) (*Container, error) {
+	container := NewContainer(client)
+	err := container.Attach(ctx, handle)
 	if err != nil {
+		return nil, err
+	}
+	return container, nil
+}
+
+func (c *Container) Handle() string {
+	return c.handle
+}
+
+func (c *Container) Attach(ctx context.Context, handle string) error {
+	err := c.retrying(ctx, func() error {
+		_, err := c.client.Info(handle)
+		return err
+	})
+	if err == context.Canceled || err == context.DeadlineExceeded {
 		return err
 	}
-	c.handle = response.GetHandle()
+	if err != nil {
+		return fmt.Errorf("container %s not found: %s", handle, err)
+	}
+	c.handle = handle
 	return nil
 }
 
-func (c *Container) ConfigureApplicationPorts() {
+func (c *Container) Create(ctx context.Context, spec *ContainerSpec) error {
+	var bindMountRequests []*warden.CreateRequest_BindMount
+	for _, bindMount := range spec.BindMounts {
+		bindMountRequest, err := bindMount.toRequest()
+		if err != nil {
+			return err
+		}
+		bindMountRequests = append(bindMountRequests, bindMountRequest)
+	}
+	request := &warden.CreateRequest{
+		BindMounts: bindMountRequests,
+		Properties: propertiesToRequest(spec.Properties),
+	}
+	if spec.GraceTimeInSeconds != 0 {
+		request.GraceTime = &spec.GraceTimeInSeconds
+	}
+	if c.retryPolicy.Attempts <= 1 {
+		return c.create(ctx, request, "")
+	}
+
+	token, err := newCreateToken()
+	if err != nil {
+		return err
+	}
+	key := PropertyCreateToken
+	request.Properties = append(request.Properties, &warden.Property{Key: &key, Value: &token})
+
+	retried := false
+	return retry(ctx, c.retryPolicy, func() error {
+		if retried {
+			handles, err := c.findCreated(ctx, token)
+			if err != nil {
+				return err
+			}
+			if len(handles) > 0 {
+				c.handle = handles[0]
+				return nil
+			}
+		}
+		retried = true
+		return c.create(ctx, request, token)
+	})
+}
+
+type createResult struct {
+	handle string
+	err    error
+}
+
+func (c *Container) create(ctx context.Context, request *warden.CreateRequest, token string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	finished := make(chan createResult, 1)
+	go func() {
+		response, err := c.client.CreateByRequest(request)
+		finished <- createResult{handle: response.GetHandle(), err: err}
+	}()
 
+	select {
+	case result := <-finished:
+		if result.err != nil {
+			return result.err
+		}
+		c.handle = result.handle
+		return nil
+	case <-ctx.Done():
+		c.destroyAbandoned(finished, token)
+		return ctx.Err()
+	}
 }
 
-func (c *Container) ConfigureConsolePorts() {
+func (c *Container) destroyAbandoned(finished <-chan createResult, token string) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultCleanupTimeout)
+	defer cancel()
+
+	var handles []string
+	select {
+	case result := <-finished:
+		if result.err == nil {
+			handles = []string{result.handle}
+		} else if token != "" {
+			handles, _ = c.findCreated(ctx, token)
+		}
+	case <-ctx.Done():
+		return
+	}
 
+	for _, handle := range handles {
+		handle := handle
+		withContext(ctx, func() error {
+			_, err := c.client.Destroy(handle)
+			return err
+		})
+	}
 }
 
-func (c *Container) ConfigureDebugPorts() {
+func (c *Container) findCreated(ctx context.Context, token string) ([]string, error) {
+	err := withContext(ctx, c.client.Connect)
+	if err != nil {
+		return nil, err
+	}
+	return FindContainers(ctx, c.client, map[string]string{PropertyCreateToken: token})
+}
 
+func (c *Container) ConfigureApplicationPorts(ctx context.Context) (*PortMapping, error) {
+	return c.mapPort(ctx)
 }
 
-func (c *Container) SetDiskLimit(limitInBytes uint64) error {
-	_, err := c.client.LimitDisk(c.handle, limitInBytes)
-	return err
+func (c *Container) ConfigureConsolePorts(ctx context.Context) (*PortMapping, error) {
+	return c.mapPort(ctx)
 }
 
-func (c *Container) SetMemoryLimit(limitInBytes uint64) error {
-	_, err := c.client.LimitMemory(c.handle, limitInBytes)
-	return err
+func (c *Container) ConfigureDebugPorts(ctx context.Context) (*PortMapping, error) {
+	return c.mapPort(ctx)
 }
 
-func (c *Container) ConfigureHomeDirectory() {
+func (c *Container) mapPort(ctx context.Context) (*PortMapping, error) {
+	var response *warden.NetInResponse
+	err := withContext(ctx, func() (err error) {
+		response, err = c.client.NetIn(c.handle)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &PortMapping{
+		HostPort:      response.GetHostPort(),
+		ContainerPort: response.GetContainerPort(),
+	}, nil
+}
 
+func (c *Container) SetDiskQuota(ctx context.Context, quota DiskQuota) (*DiskQuota, error) {
+	request := &warden.LimitDiskRequest{Handle: &c.handle}
+	if quota.ByteHard != 0 {
+		request.ByteHard = &quota.ByteHard
+	}
+	if quota.ByteSoft != 0 {
+		request.ByteSoft = &quota.ByteSoft
+	}
+	if quota.InodeHard != 0 {
+		request.InodeHard = &quota.InodeHard
+	}
+	if quota.InodeSoft != 0 {
+		request.InodeSoft = &quota.InodeSoft
+	}
+
+	var response *warden.LimitDiskResponse
+	err := c.retrying(ctx, func() (err error) {
+		response, err = c.client.LimitDiskByRequest(request)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &DiskQuota{
+		ByteSoft:  response.GetByteSoft(),
+		ByteHard:  response.GetByteHard(),
+		InodeSoft: response.GetInodeSoft(),
+		InodeHard: response.GetInodeHard(),
+	}, nil
+}
+
+func (c *Container) SetMemoryLimit(ctx context.Context, limitInBytes uint64) (uint64, error) {
+	var response *warden.LimitMemoryResponse
+	err := c.retrying(ctx, func() (err error) {
+		response, err = c.client.LimitMemory(c.handle, limitInBytes)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return response.GetLimitInBytes(), nil
+}
+
+func (c *Container) SetCpuLimit(ctx context.Context, limitInShares uint64) error {
+	return c.retrying(ctx, func() error {
+		_, err := c.client.LimitCpu(c.handle, limitInShares)
+		return err
+	})
+}
+
+func (c *Container) SetBandwidthLimit(ctx context.Context, rateInBytesPerSecond, burstInBytes uint64) error {
+	return c.retrying(ctx, func() error {
+		_, err := c.client.LimitBandwidth(c.handle, rateInBytesPerSecond, burstInBytes)
+		return err
+	})
+}
+
+func (c *Container) SetFileDescriptorLimit(limit uint64) error {
+	if limit == 0 {
+		return errors.New("file descriptor limit must be greater than zero")
+	}
+	if c.rlimits == nil {
+		c.rlimits = &warden.ResourceLimits{}
+	}
+	c.rlimits.Nofile = &limit
+	return nil
+}
+
+func (c *Container) ConfigureHomeDirectory(ctx context.Context) error {
+	directories := []string{
+		homeDirectoryPath + "/app",
+		homeDirectoryPath + "/logs",
+		homeDirectoryPath + "/tmp",
+	}
+	script := strings.Join([]string{
+		fmt.Sprintf("mkdir -p %s", strings.Join(directories, " ")),
+		fmt.Sprintf("chown %s:%s %s %s", vcapUser, vcapUser, homeDirectoryPath, strings.Join(directories, " ")),
+		fmt.Sprintf("chmod 0755 %s %s/app %s/logs", homeDirectoryPath, homeDirectoryPath, homeDirectoryPath),
+		fmt.Sprintf("chmod 1777 %s/tmp", homeDirectoryPath),
+	}, " && ")
+
+	return c.runPrivileged(ctx, script)
+}
+
+func (c *Container) runPrivileged(ctx context.Context, script string) error {
+	privileged := true
+	var response *warden.RunResponse
+	err := withContext(ctx, func() (err error) {
+		response, err = c.client.RunByRequest(&warden.RunRequest{
+			Handle:     &c.handle,
+			Script:     &script,
+			Privileged: &privileged,
+			Rlimits:    c.rlimits,
+		})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if response.GetExitStatus() != 0 {
+		return fmt.Errorf("script exited with status %d: %s", response.GetExitStatus(), response.GetStderr())
+	}
+	return nil
+}
+
+func (c *Container) OutOfMemory(ctx context.Context) (bool, error) {
+	var response *warden.InfoResponse
+	err := c.retrying(ctx, func() (err error) {
+		response, err = c.client.Info(c.handle)
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+	for _, event := range response.GetEvents() {
+		if event == outOfMemoryEvent {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (c *Container) Stop(ctx context.Context) error {
+	if c.handle == "" {
+		return nil
+	}
+	return c.retrying(ctx, func() error {
+		_, err := c.client.Stop(c.handle, false, false)
+		return err
+	})
+}
+
+func (c *Container) Kill(ctx context.Context) error {
+	if c.handle == "" {
+		return nil
+	}
+	return c.retrying(ctx, func() error {
+		_, err := c.client.Stop(c.handle, false, true)
+		return err
+	})
+}
+
+func (c *Container) Destroy(ctx context.Context) error {
+	if c.handle == "" {
+		return nil
+	}
+	err := c.retrying(ctx, func() error {
+		_, err := c.client.Destroy(c.handle)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	c.handle = ""
+	return nil
+}
+
+func withContext(ctx context.Context, request func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	finished := make(chan error, 1)
+	go func() {
+		finished <- request()
+	}()
+
+	select {
+	case err := <-finished:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
@@ -1,10 +1,12 @@
 package container
 
 import (
+	"context"
 	"errors"
 	warden "github.com/cloudfoundry/gordon"
 	. "launchpad.net/gocheck"
 	"testing"
+	"time"
 )
 
 func Test(t *testing.T) { TestingT(t) }
@@ -31,89 +33,255 @@ func (suite *ContainerSuite) TestCreate(c *C) {
 		DstPath: "/tmp/bar",
 		Mode:    "RO",
 	}
-	err := container.Create([]*BindMount{inputBindMount})
+	err := container.Create(context.Background(), &ContainerSpec{BindMounts: []*BindMount{inputBindMount}})
 
 	c.Assert(len(request.GetBindMounts()), Equals, 1)
 	bindMount := request.GetBindMounts()[0]
 	c.Assert(*bindMount.SrcPath, Equals, "/tmp/foo")
 	c.Assert(*bindMount.DstPath, Equals, "/tmp/bar")
 	c.Assert(*bindMount.Mode, Equals, warden.CreateRequest_BindMount_RO)
+	c.Assert(*bindMount.Origin, Equals, warden.CreateRequest_BindMount_Host)
+	c.Assert(len(request.GetProperties()), Equals, 0)
+	c.Assert(request.GraceTime, IsNil)
 
 	c.Assert(err, IsNil)
 	c.Assert(container.handle, Equals, "wardenhandle")
 }
 
-func (suite *ContainerSuite) TestCreateError(c *C) {
+func (suite *ContainerSuite) TestAttachContainer(c *C) {
+	var infoHandle string
 	fakeClient := MakeFakeWardenClient()
-	fakeClient.CreateByRequestFunc = func(*warden.CreateRequest) (*warden.CreateResponse, error) {
-		return nil, errors.New("no client for you")
+	fakeClient.InfoFunc = func(handle string) (*warden.InfoResponse, error) {
+		infoHandle = handle
+		return &warden.InfoResponse{}, nil
 	}
-	container := NewContainer(fakeClient)
 
-	err := container.Create([]*BindMount{})
+	container, err := AttachContainer(context.Background(), fakeClient, "existing-handle")
 
-	c.Assert(err.Error(), Equals, "no client for you")
-	c.Assert(container.handle, Equals, "")
+	c.Assert(err, IsNil)
+	c.Assert(infoHandle, Equals, "existing-handle")
+	c.Assert(container.Handle(), Equals, "existing-handle")
 }
 
-func (suite *ContainerSuite) TestSetDiskLimit(c *C) {
-	var handle string
-	var limit uint64
+func (suite *ContainerSuite) TestAttachContainerToMissingHandle(c *C) {
 	fakeClient := MakeFakeWardenClient()
-	fakeClient.LimitDiskFunc = func(h string, l uint64) (*warden.LimitDiskResponse, error) {
-		handle = h
-		limit = l
-		return nil, nil
+	fakeClient.InfoFunc = func(string) (*warden.InfoResponse, error) {
+		return nil, errors.New("unknown handle")
 	}
 
+	container, err := AttachContainer(context.Background(), fakeClient, "missing-handle")
+
+	c.Assert(container, IsNil)
+	c.Assert(err.Error(), Equals, "container missing-handle not found: unknown handle")
+}
+
+func (suite *ContainerSuite) TestCreateWithPropertiesAndGraceTime(c *C) {
+	var request *warden.CreateRequest
+	fakeClient := MakeFakeWardenClient()
+	fakeClient.CreateByRequestFunc = func(r *warden.CreateRequest) (*warden.CreateResponse, error) {
+		request = r
+		return &warden.CreateResponse{}, nil
+	}
 	container := NewContainer(fakeClient)
-	container.handle = "the_warden_handle"
 
-	container.SetDiskLimit(123)
+	err := container.Create(context.Background(), &ContainerSpec{
+		Properties: map[string]string{
+			"app_name":       "simple-app",
+			"instance_index": "2",
+		},
+		GraceTimeInSeconds: 300,
+	})
 
-	c.Assert(handle, Equals, "the_warden_handle")
-	c.Assert(limit, Equals, uint64(123))
+	c.Assert(err, IsNil)
+	c.Assert(request.GetGraceTime(), Equals, uint32(300))
+	properties := request.GetProperties()
+	c.Assert(len(properties), Equals, 2)
+	c.Assert(properties[0].GetKey(), Equals, "app_name")
+	c.Assert(properties[0].GetValue(), Equals, "simple-app")
+	c.Assert(properties[1].GetKey(), Equals, "instance_index")
+	c.Assert(properties[1].GetValue(), Equals, "2")
 }
 
-func (suite *ContainerSuite) TestSetDiskLimitError(c *C) {
+func (suite *ContainerSuite) TestCreateBindMountModesAndOrigins(c *C) {
+	var request *warden.CreateRequest
 	fakeClient := MakeFakeWardenClient()
-	fakeClient.LimitDiskFunc = func(h string, l uint64) (*warden.LimitDiskResponse, error) {
-		return nil, errors.New("failed to limit disk")
+	fakeClient.CreateByRequestFunc = func(r *warden.CreateRequest) (*warden.CreateResponse, error) {
+		request = r
+		return &warden.CreateResponse{}, nil
 	}
+	container := NewContainer(fakeClient)
+
+	err := container.Create(context.Background(), &ContainerSpec{BindMounts: []*BindMount{
+		{SrcPath: "/tmp/a", DstPath: "/tmp/a", Mode: "rw"},
+		{SrcPath: "/tmp/b", DstPath: "/tmp/b", Mode: "Ro", Origin: "CONTAINER"},
+		{SrcPath: "/tmp/c", DstPath: "/tmp/c", Origin: "host"},
+	}})
+
+	c.Assert(err, IsNil)
+	bindMounts := request.GetBindMounts()
+	c.Assert(len(bindMounts), Equals, 3)
+	c.Assert(*bindMounts[0].Mode, Equals, warden.CreateRequest_BindMount_RW)
+	c.Assert(*bindMounts[0].Origin, Equals, warden.CreateRequest_BindMount_Host)
+	c.Assert(*bindMounts[1].Mode, Equals, warden.CreateRequest_BindMount_RO)
+	c.Assert(*bindMounts[1].Origin, Equals, warden.CreateRequest_BindMount_Container)
+	c.Assert(*bindMounts[2].Mode, Equals, warden.CreateRequest_BindMount_RO)
+	c.Assert(*bindMounts[2].Origin, Equals, warden.CreateRequest_BindMount_Host)
+}
 
+func (suite *ContainerSuite) TestCreateRejectsUnknownBindMountMode(c *C) {
+	createCalled := false
+	fakeClient := MakeFakeWardenClient()
+	fakeClient.CreateByRequestFunc = func(*warden.CreateRequest) (*warden.CreateResponse, error) {
+		createCalled = true
+		return &warden.CreateResponse{}, nil
+	}
 	container := NewContainer(fakeClient)
-	container.handle = "the_warden_handle"
 
-	err := container.SetDiskLimit(123)
-	c.Assert(err.Error(), Equals, "failed to limit disk")
+	err := container.Create(context.Background(), &ContainerSpec{BindMounts: []*BindMount{{SrcPath: "/tmp/a", DstPath: "/tmp/b", Mode: "rwx"}}})
+
+	c.Assert(err.Error(), Equals, `invalid mode "rwx" for bind mount /tmp/b, expected RO or RW`)
+	c.Assert(createCalled, Equals, false)
+}
+
+func (suite *ContainerSuite) TestCreateRejectsUnknownBindMountOrigin(c *C) {
+	container := NewContainer(MakeFakeWardenClient())
+
+	err := container.Create(context.Background(), &ContainerSpec{BindMounts: []*BindMount{{SrcPath: "/tmp/a", DstPath: "/tmp/b", Origin: "guest"}}})
+
+	c.Assert(err.Error(), Equals, `invalid origin "guest" for bind mount /tmp/b, expected host or container`)
+}
+
+func (suite *ContainerSuite) TestCreateError(c *C) {
+	fakeClient := MakeFakeWardenClient()
+	fakeClient.CreateByRequestFunc = func(*warden.CreateRequest) (*warden.CreateResponse, error) {
+		return nil, errors.New("no client for you")
+	}
+	container := NewContainer(fakeClient)
+
+	err := container.Create(context.Background(), &ContainerSpec{})
+
+	c.Assert(err.Error(), Equals, "no client for you")
+	c.Assert(container.handle, Equals, "")
 }
 
 type fakeWardenClient struct {
-	CreateByRequestFunc func(*warden.CreateRequest) (*warden.CreateResponse, error)
-	LimitDiskFunc       func(string, uint64) (*warden.LimitDiskResponse, error)
-	LimitMemoryFunc     func(string, uint64) (*warden.LimitMemoryResponse, error)
+	ConnectFunc            func() error
+	CreateByRequestFunc    func(*warden.CreateRequest) (*warden.CreateResponse, error)
+	LimitMemoryFunc        func(string, uint64) (*warden.LimitMemoryResponse, error)
+	DestroyFunc            func(string) (*warden.DestroyResponse, error)
+	NetInFunc              func(string) (*warden.NetInResponse, error)
+	RunByRequestFunc       func(*warden.RunRequest) (*warden.RunResponse, error)
+	SpawnByRequestFunc     func(*warden.SpawnRequest) (*warden.SpawnResponse, error)
+	LinkFunc               func(string, uint32) (*warden.LinkResponse, error)
+	CopyInFunc             func(string, string, string) (*warden.CopyInResponse, error)
+	CopyOutFunc            func(string, string, string, string) (*warden.CopyOutResponse, error)
+	StreamFunc             func(string, uint32) (chan *warden.StreamResponse, error)
+	StopFunc               func(string, bool, bool) (*warden.StopResponse, error)
+	InfoFunc               func(string) (*warden.InfoResponse, error)
+	ListByRequestFunc      func(*warden.ListRequest) (*warden.ListResponse, error)
+	NetOutByRequestFunc    func(*warden.NetOutRequest) (*warden.NetOutResponse, error)
+	LimitCpuFunc           func(string, uint64) (*warden.LimitCpuResponse, error)
+	LimitDiskByRequestFunc func(*warden.LimitDiskRequest) (*warden.LimitDiskResponse, error)
+	LimitBandwidthFunc     func(string, uint64, uint64) (*warden.LimitBandwidthResponse, error)
 }
 
 func MakeFakeWardenClient() *fakeWardenClient {
 	return &fakeWardenClient{
 		CreateByRequestFunc: func(*warden.CreateRequest) (*warden.CreateResponse, error) { return nil, nil },
-		LimitDiskFunc:       func(string, uint64) (*warden.LimitDiskResponse, error) { return nil, nil },
+		ConnectFunc:         func() error { return nil },
 		LimitMemoryFunc:     func(string, uint64) (*warden.LimitMemoryResponse, error) { return nil, nil },
+		DestroyFunc:         func(string) (*warden.DestroyResponse, error) { return nil, nil },
+		NetInFunc:           func(string) (*warden.NetInResponse, error) { return nil, nil },
+		RunByRequestFunc:    func(*warden.RunRequest) (*warden.RunResponse, error) { return &warden.RunResponse{}, nil },
+		SpawnByRequestFunc:  func(*warden.SpawnRequest) (*warden.SpawnResponse, error) { return &warden.SpawnResponse{}, nil },
+		LinkFunc:            func(string, uint32) (*warden.LinkResponse, error) { return &warden.LinkResponse{}, nil },
+		CopyInFunc:          func(string, string, string) (*warden.CopyInResponse, error) { return nil, nil },
+		CopyOutFunc:         func(string, string, string, string) (*warden.CopyOutResponse, error) { return nil, nil },
+		StreamFunc:          func(string, uint32) (chan *warden.StreamResponse, error) { return nil, nil },
+		StopFunc:            func(string, bool, bool) (*warden.StopResponse, error) { return nil, nil },
+		LimitCpuFunc:        func(string, uint64) (*warden.LimitCpuResponse, error) { return nil, nil },
+		InfoFunc:            func(string) (*warden.InfoResponse, error) { return &warden.InfoResponse{}, nil },
+		ListByRequestFunc:   func(*warden.ListRequest) (*warden.ListResponse, error) { return &warden.ListResponse{}, nil },
+		NetOutByRequestFunc: func(*warden.NetOutRequest) (*warden.NetOutResponse, error) { return nil, nil },
+		LimitDiskByRequestFunc: func(*warden.LimitDiskRequest) (*warden.LimitDiskResponse, error) {
+			return &warden.LimitDiskResponse{}, nil
+		},
+		LimitBandwidthFunc: func(string, uint64, uint64) (*warden.LimitBandwidthResponse, error) { return nil, nil },
 	}
 }
 
-func (c *fakeWardenClient) CreateByRequest(r *warden.CreateRequest) (*warden.CreateResponse, error) {
-	return c.CreateByRequestFunc(r)
+func (c *fakeWardenClient) Connect() error {
+	return c.ConnectFunc()
 }
 
-func (c *fakeWardenClient) LimitDisk(handle string, limit uint64) (*warden.LimitDiskResponse, error) {
-	return c.LimitDiskFunc(handle, limit)
+func (c *fakeWardenClient) CreateByRequest(r *warden.CreateRequest) (*warden.CreateResponse, error) {
+	return c.CreateByRequestFunc(r)
 }
 
 func (c *fakeWardenClient) LimitMemory(handle string, limit uint64) (*warden.LimitMemoryResponse, error) {
 	return c.LimitMemoryFunc(handle, limit)
 }
 
+func (c *fakeWardenClient) Destroy(handle string) (*warden.DestroyResponse, error) {
+	return c.DestroyFunc(handle)
+}
+
+func (c *fakeWardenClient) NetIn(handle string) (*warden.NetInResponse, error) {
+	return c.NetInFunc(handle)
+}
+
+func (c *fakeWardenClient) RunByRequest(r *warden.RunRequest) (*warden.RunResponse, error) {
+	return c.RunByRequestFunc(r)
+}
+
+func (c *fakeWardenClient) SpawnByRequest(r *warden.SpawnRequest) (*warden.SpawnResponse, error) {
+	return c.SpawnByRequestFunc(r)
+}
+
+func (c *fakeWardenClient) Link(handle string, jobId uint32) (*warden.LinkResponse, error) {
+	return c.LinkFunc(handle, jobId)
+}
+
+func (c *fakeWardenClient) CopyIn(handle, srcPath, dstPath string) (*warden.CopyInResponse, error) {
+	return c.CopyInFunc(handle, srcPath, dstPath)
+}
+
+func (c *fakeWardenClient) CopyOut(handle, srcPath, dstPath, owner string) (*warden.CopyOutResponse, error) {
+	return c.CopyOutFunc(handle, srcPath, dstPath, owner)
+}
+
+func (c *fakeWardenClient) Stream(handle string, jobId uint32) (chan *warden.StreamResponse, error) {
+	return c.StreamFunc(handle, jobId)
+}
+
+func (c *fakeWardenClient) Stop(handle string, background, kill bool) (*warden.StopResponse, error) {
+	return c.StopFunc(handle, background, kill)
+}
+
+func (c *fakeWardenClient) LimitDiskByRequest(r *warden.LimitDiskRequest) (*warden.LimitDiskResponse, error) {
+	return c.LimitDiskByRequestFunc(r)
+}
+
+func (c *fakeWardenClient) Info(handle string) (*warden.InfoResponse, error) {
+	return c.InfoFunc(handle)
+}
+
+func (c *fakeWardenClient) NetOutByRequest(r *warden.NetOutRequest) (*warden.NetOutResponse, error) {
+	return c.NetOutByRequestFunc(r)
+}
+
+func (c *fakeWardenClient) ListByRequest(r *warden.ListRequest) (*warden.ListResponse, error) {
+	return c.ListByRequestFunc(r)
+}
+
+func (c *fakeWardenClient) LimitCpu(handle string, limit uint64) (*warden.LimitCpuResponse, error) {
+	return c.LimitCpuFunc(handle, limit)
+}
+
+func (c *fakeWardenClient) LimitBandwidth(handle string, rate, burst uint64) (*warden.LimitBandwidthResponse, error) {
+	return c.LimitBandwidthFunc(handle, rate, burst)
+}
+
 func (suite *ContainerSuite) TestSetMemoryLimit(c *C) {
 	var handle string
 	var limit uint64
@@ -121,16 +289,18 @@ func (suite *ContainerSuite) TestSetMemoryLimit(c *C) {
 	fakeClient.LimitMemoryFunc = func(h string, l uint64) (*warden.LimitMemoryResponse, error) {
 		handle = h
 		limit = l
-		return nil, nil
+		return &warden.LimitMemoryResponse{LimitInBytes: &l}, nil
 	}
 
 	container := NewContainer(fakeClient)
 	container.handle = "the_warden_handle"
 
-	container.SetMemoryLimit(123)
+	applied, err := container.SetMemoryLimit(context.Background(), 123)
 
+	c.Assert(err, IsNil)
 	c.Assert(handle, Equals, "the_warden_handle")
 	c.Assert(limit, Equals, uint64(123))
+	c.Assert(applied, Equals, uint64(123))
 }
 
 func (suite *ContainerSuite) TestSetMemoryLimitError(c *C) {
@@ -142,6 +312,474 @@ func (suite *ContainerSuite) TestSetMemoryLimitError(c *C) {
 	container := NewContainer(fakeClient)
 	container.handle = "the_warden_handle"
 
-	err := container.SetMemoryLimit(123)
+	_, err := container.SetMemoryLimit(context.Background(), 123)
 	c.Assert(err.Error(), Equals, "failed to limit memory")
 }
+
+func (suite *ContainerSuite) TestCancellingAbandonsTheWardenRequest(c *C) {
+	released := make(chan struct{})
+	defer close(released)
+	fakeClient := MakeFakeWardenClient()
+	fakeClient.LimitMemoryFunc = func(string, uint64) (*warden.LimitMemoryResponse, error) {
+		<-released
+		return nil, nil
+	}
+
+	container := NewContainer(fakeClient)
+	container.handle = "the_warden_handle"
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	_, err := container.SetMemoryLimit(ctx, 123)
+	c.Assert(err, Equals, context.Canceled)
+}
+
+func (suite *ContainerSuite) TestCancelledContextSkipsTheWardenRequest(c *C) {
+	created := false
+	fakeClient := MakeFakeWardenClient()
+	fakeClient.CreateByRequestFunc = func(*warden.CreateRequest) (*warden.CreateResponse, error) {
+		created = true
+		return &warden.CreateResponse{}, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	container := NewContainer(fakeClient)
+	err := container.Create(ctx, &ContainerSpec{})
+
+	c.Assert(err, Equals, context.Canceled)
+	c.Assert(created, Equals, false)
+	c.Assert(container.Handle(), Equals, "")
+}
+
+func (suite *ContainerSuite) TestCancellingCreateDestroysTheAbandonedContainer(c *C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	released := make(chan struct{})
+	go func() {
+		<-ctx.Done()
+		time.Sleep(10 * time.Millisecond)
+		close(released)
+	}()
+	destroyed := make(chan string, 1)
+	fakeClient := MakeFakeWardenClient()
+	fakeClient.CreateByRequestFunc = func(*warden.CreateRequest) (*warden.CreateResponse, error) {
+		<-released
+		handle := "abandoned-handle"
+		return &warden.CreateResponse{Handle: &handle}, nil
+	}
+	fakeClient.DestroyFunc = func(handle string) (*warden.DestroyResponse, error) {
+		destroyed <- handle
+		return nil, nil
+	}
+
+	container := NewContainer(fakeClient)
+	time.AfterFunc(10*time.Millisecond, cancel)
+	err := container.Create(ctx, &ContainerSpec{})
+
+	c.Assert(err, Equals, context.Canceled)
+	c.Assert(container.Handle(), Equals, "")
+	c.Assert(<-destroyed, Equals, "abandoned-handle")
+}
+
+func (suite *ContainerSuite) TestCancellingCreateFindsTheAbandonedContainerByToken(c *C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	released := make(chan struct{})
+	go func() {
+		<-ctx.Done()
+		time.Sleep(10 * time.Millisecond)
+		close(released)
+	}()
+	var token string
+	destroyed := make(chan string, 1)
+	fakeClient := MakeFakeWardenClient()
+	fakeClient.CreateByRequestFunc = func(r *warden.CreateRequest) (*warden.CreateResponse, error) {
+		token = r.Properties[len(r.Properties)-1].GetValue()
+		<-released
+		return nil, errors.New("connection reset by peer")
+	}
+	fakeClient.ListByRequestFunc = func(r *warden.ListRequest) (*warden.ListResponse, error) {
+		c.Assert(r.Properties[0].GetValue(), Equals, token)
+		return &warden.ListResponse{Handles: []string{"abandoned-handle"}}, nil
+	}
+	fakeClient.DestroyFunc = func(handle string) (*warden.DestroyResponse, error) {
+		destroyed <- handle
+		return nil, nil
+	}
+
+	container := NewContainer(fakeClient)
+	container.SetRetryPolicy(RetryPolicy{Attempts: 3, InitialDelay: time.Millisecond, MaxDelay: time.Millisecond})
+	time.AfterFunc(10*time.Millisecond, cancel)
+	err := container.Create(ctx, &ContainerSpec{})
+
+	c.Assert(err, Equals, context.Canceled)
+	c.Assert(<-destroyed, Equals, "abandoned-handle")
+}
+
+func (suite *ContainerSuite) TestAttachDoesNotReportCancellationAsMissingContainer(c *C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	container, err := AttachContainer(ctx, MakeFakeWardenClient(), "existing-handle")
+
+	c.Assert(container, IsNil)
+	c.Assert(err, Equals, context.Canceled)
+}
+
+func (suite *ContainerSuite) TestDestroy(c *C) {
+	var handle string
+	fakeClient := MakeFakeWardenClient()
+	fakeClient.DestroyFunc = func(h string) (*warden.DestroyResponse, error) {
+		handle = h
+		return nil, nil
+	}
+
+	container := NewContainer(fakeClient)
+	container.handle = "the_warden_handle"
+
+	err := container.Destroy(context.Background())
+
+	c.Assert(err, IsNil)
+	c.Assert(handle, Equals, "the_warden_handle")
+	c.Assert(container.handle, Equals, "")
+}
+
+func (suite *ContainerSuite) TestDestroyWithoutHandle(c *C) {
+	destroyed := false
+	fakeClient := MakeFakeWardenClient()
+	fakeClient.DestroyFunc = func(string) (*warden.DestroyResponse, error) {
+		destroyed = true
+		return nil, nil
+	}
+
+	container := NewContainer(fakeClient)
+
+	c.Assert(container.Destroy(context.Background()), IsNil)
+	c.Assert(destroyed, Equals, false)
+}
+
+func (suite *ContainerSuite) TestDestroyError(c *C) {
+	fakeClient := MakeFakeWardenClient()
+	fakeClient.DestroyFunc = func(string) (*warden.DestroyResponse, error) {
+		return nil, errors.New("failed to destroy")
+	}
+
+	container := NewContainer(fakeClient)
+	container.handle = "the_warden_handle"
+
+	err := container.Destroy(context.Background())
+	c.Assert(err.Error(), Equals, "failed to destroy")
+	c.Assert(container.handle, Equals, "the_warden_handle")
+}
+
+func (suite *ContainerSuite) TestConfigurePorts(c *C) {
+	var handles []string
+	nextPort := uint32(61000)
+	fakeClient := MakeFakeWardenClient()
+	fakeClient.NetInFunc = func(h string) (*warden.NetInResponse, error) {
+		handles = append(handles, h)
+		hostPort := nextPort
+		containerPort := nextPort - 50000
+		nextPort++
+		return &warden.NetInResponse{HostPort: &hostPort, ContainerPort: &containerPort}, nil
+	}
+
+	container := NewContainer(fakeClient)
+	container.handle = "the_warden_handle"
+
+	application, err := container.ConfigureApplicationPorts(context.Background())
+	c.Assert(err, IsNil)
+	console, err := container.ConfigureConsolePorts(context.Background())
+	c.Assert(err, IsNil)
+	debug, err := container.ConfigureDebugPorts(context.Background())
+	c.Assert(err, IsNil)
+
+	c.Assert(handles, DeepEquals, []string{"the_warden_handle", "the_warden_handle", "the_warden_handle"})
+	c.Assert(*application, Equals, PortMapping{HostPort: 61000, ContainerPort: 11000})
+	c.Assert(*console, Equals, PortMapping{HostPort: 61001, ContainerPort: 11001})
+	c.Assert(*debug, Equals, PortMapping{HostPort: 61002, ContainerPort: 11002})
+}
+
+func (suite *ContainerSuite) TestConfigurePortsError(c *C) {
+	fakeClient := MakeFakeWardenClient()
+	fakeClient.NetInFunc = func(string) (*warden.NetInResponse, error) {
+		return nil, errors.New("failed to map port")
+	}
+
+	container := NewContainer(fakeClient)
+	container.handle = "the_warden_handle"
+
+	mapping, err := container.ConfigureApplicationPorts(context.Background())
+	c.Assert(mapping, IsNil)
+	c.Assert(err.Error(), Equals, "failed to map port")
+}
+
+func (suite *ContainerSuite) TestConfigureHomeDirectory(c *C) {
+	var request *warden.RunRequest
+	fakeClient := MakeFakeWardenClient()
+	fakeClient.RunByRequestFunc = func(r *warden.RunRequest) (*warden.RunResponse, error) {
+		request = r
+		return &warden.RunResponse{}, nil
+	}
+
+	container := NewContainer(fakeClient)
+	container.handle = "the_warden_handle"
+
+	err := container.ConfigureHomeDirectory(context.Background())
+
+	c.Assert(err, IsNil)
+	c.Assert(request.GetHandle(), Equals, "the_warden_handle")
+	c.Assert(request.GetPrivileged(), Equals, true)
+	c.Assert(request.GetScript(), Equals,
+		"mkdir -p /home/vcap/app /home/vcap/logs /home/vcap/tmp && "+
+			"chown vcap:vcap /home/vcap /home/vcap/app /home/vcap/logs /home/vcap/tmp && "+
+			"chmod 0755 /home/vcap /home/vcap/app /home/vcap/logs && "+
+			"chmod 1777 /home/vcap/tmp")
+}
+
+func (suite *ContainerSuite) TestConfigureHomeDirectoryError(c *C) {
+	fakeClient := MakeFakeWardenClient()
+	fakeClient.RunByRequestFunc = func(*warden.RunRequest) (*warden.RunResponse, error) {
+		return nil, errors.New("failed to run")
+	}
+
+	container := NewContainer(fakeClient)
+	container.handle = "the_warden_handle"
+
+	err := container.ConfigureHomeDirectory(context.Background())
+	c.Assert(err.Error(), Equals, "failed to run")
+}
+
+func (suite *ContainerSuite) TestConfigureHomeDirectoryNonZeroExitStatus(c *C) {
+	fakeClient := MakeFakeWardenClient()
+	fakeClient.RunByRequestFunc = func(*warden.RunRequest) (*warden.RunResponse, error) {
+		exitStatus := uint32(1)
+		stderr := "chown: invalid user"
+		return &warden.RunResponse{ExitStatus: &exitStatus, Stderr: &stderr}, nil
+	}
+
+	container := NewContainer(fakeClient)
+	container.handle = "the_warden_handle"
+
+	err := container.ConfigureHomeDirectory(context.Background())
+	c.Assert(err.Error(), Equals, "script exited with status 1: chown: invalid user")
+}
+
+func (suite *ContainerSuite) TestStop(c *C) {
+	var handle string
+	var background, kill bool
+	fakeClient := MakeFakeWardenClient()
+	fakeClient.StopFunc = func(h string, b, k bool) (*warden.StopResponse, error) {
+		handle, background, kill = h, b, k
+		return nil, nil
+	}
+
+	container := NewContainer(fakeClient)
+	container.handle = "the_warden_handle"
+
+	err := container.Stop(context.Background())
+
+	c.Assert(err, IsNil)
+	c.Assert(handle, Equals, "the_warden_handle")
+	c.Assert(background, Equals, false)
+	c.Assert(kill, Equals, false)
+}
+
+func (suite *ContainerSuite) TestKill(c *C) {
+	var handle string
+	var background, kill bool
+	fakeClient := MakeFakeWardenClient()
+	fakeClient.StopFunc = func(h string, b, k bool) (*warden.StopResponse, error) {
+		handle, background, kill = h, b, k
+		return nil, nil
+	}
+
+	container := NewContainer(fakeClient)
+	container.handle = "the_warden_handle"
+
+	err := container.Kill(context.Background())
+
+	c.Assert(err, IsNil)
+	c.Assert(handle, Equals, "the_warden_handle")
+	c.Assert(background, Equals, false)
+	c.Assert(kill, Equals, true)
+}
+
+func (suite *ContainerSuite) TestStopError(c *C) {
+	fakeClient := MakeFakeWardenClient()
+	fakeClient.StopFunc = func(string, bool, bool) (*warden.StopResponse, error) {
+		return nil, errors.New("failed to stop")
+	}
+
+	container := NewContainer(fakeClient)
+	container.handle = "the_warden_handle"
+
+	c.Assert(container.Stop(context.Background()).Error(), Equals, "failed to stop")
+}
+
+func (suite *ContainerSuite) TestSetFileDescriptorLimitAppliesToRunAndSpawn(c *C) {
+	var runRequest *warden.RunRequest
+	var spawnRequest *warden.SpawnRequest
+	fakeClient := MakeFakeWardenClient()
+	fakeClient.RunByRequestFunc = func(r *warden.RunRequest) (*warden.RunResponse, error) {
+		runRequest = r
+		return &warden.RunResponse{}, nil
+	}
+	fakeClient.SpawnByRequestFunc = func(r *warden.SpawnRequest) (*warden.SpawnResponse, error) {
+		spawnRequest = r
+		return &warden.SpawnResponse{}, nil
+	}
+
+	container := NewContainer(fakeClient)
+	container.handle = "the_warden_handle"
+
+	err := container.SetFileDescriptorLimit(4096)
+	c.Assert(err, IsNil)
+
+	container.ConfigureHomeDirectory(context.Background())
+	container.SpawnTask(context.Background(), "", "true")
+
+	c.Assert(runRequest.GetRlimits().GetNofile(), Equals, uint64(4096))
+	c.Assert(spawnRequest.GetRlimits().GetNofile(), Equals, uint64(4096))
+}
+
+func (suite *ContainerSuite) TestSetFileDescriptorLimitRejectsZero(c *C) {
+	container := NewContainer(MakeFakeWardenClient())
+
+	err := container.SetFileDescriptorLimit(0)
+	c.Assert(err.Error(), Equals, "file descriptor limit must be greater than zero")
+	c.Assert(container.rlimits, IsNil)
+}
+
+func (suite *ContainerSuite) TestSetCpuLimit(c *C) {
+	var handle string
+	var limit uint64
+	fakeClient := MakeFakeWardenClient()
+	fakeClient.LimitCpuFunc = func(h string, l uint64) (*warden.LimitCpuResponse, error) {
+		handle, limit = h, l
+		return nil, nil
+	}
+
+	container := NewContainer(fakeClient)
+	container.handle = "the_warden_handle"
+
+	err := container.SetCpuLimit(context.Background(), 512)
+
+	c.Assert(err, IsNil)
+	c.Assert(handle, Equals, "the_warden_handle")
+	c.Assert(limit, Equals, uint64(512))
+}
+
+func (suite *ContainerSuite) TestSetCpuLimitError(c *C) {
+	fakeClient := MakeFakeWardenClient()
+	fakeClient.LimitCpuFunc = func(string, uint64) (*warden.LimitCpuResponse, error) {
+		return nil, errors.New("failed to limit cpu")
+	}
+
+	container := NewContainer(fakeClient)
+	c.Assert(container.SetCpuLimit(context.Background(), 512).Error(), Equals, "failed to limit cpu")
+}
+
+func (suite *ContainerSuite) TestSetBandwidthLimit(c *C) {
+	var handle string
+	var rate, burst uint64
+	fakeClient := MakeFakeWardenClient()
+	fakeClient.LimitBandwidthFunc = func(h string, r, b uint64) (*warden.LimitBandwidthResponse, error) {
+		handle, rate, burst = h, r, b
+		return nil, nil
+	}
+
+	container := NewContainer(fakeClient)
+	container.handle = "the_warden_handle"
+
+	err := container.SetBandwidthLimit(context.Background(), 1000, 2000)
+
+	c.Assert(err, IsNil)
+	c.Assert(handle, Equals, "the_warden_handle")
+	c.Assert(rate, Equals, uint64(1000))
+	c.Assert(burst, Equals, uint64(2000))
+}
+
+func (suite *ContainerSuite) TestSetBandwidthLimitError(c *C) {
+	fakeClient := MakeFakeWardenClient()
+	fakeClient.LimitBandwidthFunc = func(string, uint64, uint64) (*warden.LimitBandwidthResponse, error) {
+		return nil, errors.New("failed to limit bandwidth")
+	}
+
+	container := NewContainer(fakeClient)
+	c.Assert(container.SetBandwidthLimit(context.Background(), 1000, 2000).Error(), Equals, "failed to limit bandwidth")
+}
+
+func (suite *ContainerSuite) TestSetDiskQuota(c *C) {
+	var request *warden.LimitDiskRequest
+	fakeClient := MakeFakeWardenClient()
+	fakeClient.LimitDiskByRequestFunc = func(r *warden.LimitDiskRequest) (*warden.LimitDiskResponse, error) {
+		request = r
+		byteSoft, byteHard, inodeHard := uint64(90), uint64(100), uint64(1024)
+		return &warden.LimitDiskResponse{ByteSoft: &byteSoft, ByteHard: &byteHard, InodeHard: &inodeHard}, nil
+	}
+
+	container := NewContainer(fakeClient)
+	container.handle = "the_warden_handle"
+
+	applied, err := container.SetDiskQuota(context.Background(), DiskQuota{ByteSoft: 90, ByteHard: 100, InodeHard: 1000})
+
+	c.Assert(err, IsNil)
+	c.Assert(request.GetHandle(), Equals, "the_warden_handle")
+	c.Assert(request.GetByteSoft(), Equals, uint64(90))
+	c.Assert(request.GetByteHard(), Equals, uint64(100))
+	c.Assert(request.GetInodeHard(), Equals, uint64(1000))
+	c.Assert(request.InodeSoft, IsNil)
+	c.Assert(*applied, Equals, DiskQuota{ByteSoft: 90, ByteHard: 100, InodeHard: 1024})
+}
+
+func (suite *ContainerSuite) TestSetDiskQuotaError(c *C) {
+	fakeClient := MakeFakeWardenClient()
+	fakeClient.LimitDiskByRequestFunc = func(*warden.LimitDiskRequest) (*warden.LimitDiskResponse, error) {
+		return nil, errors.New("failed to limit disk")
+	}
+
+	container := NewContainer(fakeClient)
+	applied, err := container.SetDiskQuota(context.Background(), DiskQuota{ByteHard: 100})
+
+	c.Assert(applied, IsNil)
+	c.Assert(err.Error(), Equals, "failed to limit disk")
+}
+
+func (suite *ContainerSuite) TestOutOfMemory(c *C) {
+	var handle string
+	fakeClient := MakeFakeWardenClient()
+	fakeClient.InfoFunc = func(h string) (*warden.InfoResponse, error) {
+		handle = h
+		return &warden.InfoResponse{Events: []string{"something else", "out of memory"}}, nil
+	}
+
+	container := NewContainer(fakeClient)
+	container.handle = "the_warden_handle"
+
+	outOfMemory, err := container.OutOfMemory(context.Background())
+
+	c.Assert(err, IsNil)
+	c.Assert(outOfMemory, Equals, true)
+	c.Assert(handle, Equals, "the_warden_handle")
+}
+
+func (suite *ContainerSuite) TestNotOutOfMemory(c *C) {
+	container := NewContainer(MakeFakeWardenClient())
+
+	outOfMemory, err := container.OutOfMemory(context.Background())
+
+	c.Assert(err, IsNil)
+	c.Assert(outOfMemory, Equals, false)
+}
+
+func (suite *ContainerSuite) TestOutOfMemoryError(c *C) {
+	fakeClient := MakeFakeWardenClient()
+	fakeClient.InfoFunc = func(string) (*warden.InfoResponse, error) {
+		return nil, errors.New("failed to get info")
+	}
+
+	container := NewContainer(fakeClient)
+	_, err := container.OutOfMemory(context.Background())
+
+	c.Assert(err.Error(), Equals, "failed to get info")
+}
@@ -0,0 +1,140 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"github.com/cloudfoundry/app_container_setup/parser"
+	warden "github.com/cloudfoundry/gordon"
+	"net"
+	"strconv"
+	"strings"
+)
+
+type EgressRule struct {
+	Network   string `json:"network"`
+	PortRange string `json:"port_range"`
+	Protocol  string `json:"protocol"`
+}
+
+func (r *EgressRule) toRequest(handle string) (*warden.NetOutRequest, error) {
+	network, err := r.network()
+	if err != nil {
+		return nil, err
+	}
+	protocol, err := r.wardenProtocol()
+	if err != nil {
+		return nil, err
+	}
+
+	request := &warden.NetOutRequest{
+		Handle:   &handle,
+		Network:  &network,
+		Protocol: &protocol,
+	}
+	if r.PortRange != "" {
+		portRange, err := r.portRange()
+		if err != nil {
+			return nil, err
+		}
+		request.PortRange = &portRange
+	}
+	return request, nil
+}
+
+func (r *EgressRule) network() (string, error) {
+	if _, network, err := net.ParseCIDR(r.Network); err == nil {
+		return network.String(), nil
+	}
+	if ip := net.ParseIP(r.Network); ip != nil && ip.To4() != nil {
+		return ip.String() + "/32", nil
+	}
+	return "", fmt.Errorf("invalid network %q, expected a CIDR or IPv4 address", r.Network)
+}
+
+func (r *EgressRule) wardenProtocol() (warden.NetOutRequest_Protocol, error) {
+	protocol := strings.ToLower(r.Protocol)
+	switch protocol {
+	case "", "all":
+		if r.PortRange != "" {
+			return 0, fmt.Errorf("port_range %q requires protocol tcp or udp", r.PortRange)
+		}
+		return warden.NetOutRequest_ALL, nil
+	case "icmp":
+		if r.PortRange != "" {
+			return 0, fmt.Errorf("port_range %q requires protocol tcp or udp", r.PortRange)
+		}
+		return warden.NetOutRequest_ICMP, nil
+	case "tcp":
+		return warden.NetOutRequest_TCP, nil
+	case "udp":
+		return warden.NetOutRequest_UDP, nil
+	}
+	return 0, fmt.Errorf("invalid protocol %q, expected tcp, udp, icmp or all", r.Protocol)
+}
+
+func (r *EgressRule) portRange() (string, error) {
+	bounds := strings.Split(r.PortRange, "-")
+	if len(bounds) > 2 {
+		return "", fmt.Errorf("invalid port_range %q, expected a port or first-last", r.PortRange)
+	}
+
+	var ports []string
+	var previous uint64
+	for _, bound := range bounds {
+		port, err := strconv.ParseUint(strings.TrimSpace(bound), 10, 16)
+		if err != nil || port == 0 || port < previous {
+			return "", fmt.Errorf("invalid port_range %q, expected a port or first-last", r.PortRange)
+		}
+		previous = port
+		ports = append(ports, strconv.FormatUint(port, 10))
+	}
+	return strings.Join(ports, ":"), nil
+}
+
+func ServiceEgressRules(services []parser.InputServiceJSON, lookupIP func(string) ([]net.IP, error)) ([]*EgressRule, error) {
+	endpoints, err := parser.NewServiceEndpointGenerator(services).Generate()
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []*EgressRule
+	for _, endpoint := range endpoints {
+		ips, err := lookupIP(endpoint.Host)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve service host %s: %s", endpoint.Host, err)
+		}
+		resolved := false
+		for _, ip := range ips {
+			if ip.To4() == nil {
+				continue
+			}
+			resolved = true
+			rules = append(rules, &EgressRule{
+				Network:   ip.String() + "/32",
+				PortRange: strconv.Itoa(endpoint.Port),
+				Protocol:  "tcp",
+			})
+		}
+		if !resolved {
+			return nil, fmt.Errorf("service host %s has no IPv4 address", endpoint.Host)
+		}
+	}
+	return rules, nil
+}
+
+func (c *Container) AllowEgress(ctx context.Context, rules []*EgressRule) error {
+	for _, rule := range rules {
+		request, err := rule.toRequest(c.handle)
+		if err != nil {
+			return err
+		}
+		err = withContext(ctx, func() error {
+			_, err := c.client.NetOutByRequest(request)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const (
+	appDirectoryPath = homeDirectoryPath + "/app"
+	dropletPath      = homeDirectoryPath + "/droplet.tgz"
+)
+
+type Artifact struct {
+	SrcPath string `json:"src_path"`
+	DstPath string `json:"dst_path"`
+}
+
+func (c *Container) InstallDroplet(ctx context.Context, path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	if info.IsDir() {
+		err = c.copyIn(ctx, filepath.Clean(path)+"/", appDirectoryPath+"/")
+		if err != nil {
+			return err
+		}
+		return c.runPrivileged(ctx, fmt.Sprintf("chown -R %s:%s %s", vcapUser, vcapUser, appDirectoryPath))
+	}
+
+	err = c.copyIn(ctx, path, dropletPath)
+	if err != nil {
+		return err
+	}
+	return c.runPrivileged(ctx, fmt.Sprintf("tar -C %s -xzf %s && rm %s && chown -R %s:%s %s",
+		appDirectoryPath, dropletPath, dropletPath, vcapUser, vcapUser, appDirectoryPath))
+}
+
+func (c *Container) CopyOut(ctx context.Context, srcPath, dstPath string) error {
+	return withContext(ctx, func() error {
+		_, err := c.client.CopyOut(c.handle, srcPath, dstPath, "")
+		return err
+	})
+}
+
+func (c *Container) copyIn(ctx context.Context, srcPath, dstPath string) error {
+	return withContext(ctx, func() error {
+		_, err := c.client.CopyIn(c.handle, srcPath, dstPath)
+		return err
+	})
+}
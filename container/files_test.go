@@ -0,0 +1,102 @@
+package container
+
+import (
+	"context"
+	"errors"
+	warden "github.com/cloudfoundry/gordon"
+	"io/ioutil"
+	. "launchpad.net/gocheck"
+	"os"
+	"path/filepath"
+)
+
+type FilesSuite struct {
+	dropletDirectory string
+	copyInCalls      [][]string
+	scripts          []string
+	fakeClient       *fakeWardenClient
+	container        *Container
+}
+
+func init() {
+	Suite(&FilesSuite{})
+}
+
+func (s *FilesSuite) SetUpTest(c *C) {
+	var err error
+	s.dropletDirectory, err = ioutil.TempDir("", "droplet")
+	c.Assert(err, IsNil)
+
+	s.copyInCalls = nil
+	s.scripts = nil
+	s.fakeClient = MakeFakeWardenClient()
+	s.fakeClient.CopyInFunc = func(handle, srcPath, dstPath string) (*warden.CopyInResponse, error) {
+		s.copyInCalls = append(s.copyInCalls, []string{handle, srcPath, dstPath})
+		return &warden.CopyInResponse{}, nil
+	}
+	s.fakeClient.RunByRequestFunc = func(r *warden.RunRequest) (*warden.RunResponse, error) {
+		c.Assert(r.GetPrivileged(), Equals, true)
+		s.scripts = append(s.scripts, r.GetScript())
+		return &warden.RunResponse{}, nil
+	}
+	s.container = NewContainer(s.fakeClient)
+	s.container.handle = "the_warden_handle"
+}
+
+func (s *FilesSuite) TearDownTest(c *C) {
+	os.RemoveAll(s.dropletDirectory)
+}
+
+func (s *FilesSuite) TestInstallDropletTarball(c *C) {
+	tarball := filepath.Join(s.dropletDirectory, "droplet.tgz")
+	c.Assert(ioutil.WriteFile(tarball, []byte("droplet"), 0644), IsNil)
+
+	err := s.container.InstallDroplet(context.Background(), tarball)
+
+	c.Assert(err, IsNil)
+	c.Assert(s.copyInCalls, DeepEquals, [][]string{{"the_warden_handle", tarball, "/home/vcap/droplet.tgz"}})
+	c.Assert(s.scripts, DeepEquals, []string{
+		"tar -C /home/vcap/app -xzf /home/vcap/droplet.tgz && rm /home/vcap/droplet.tgz && chown -R vcap:vcap /home/vcap/app",
+	})
+}
+
+func (s *FilesSuite) TestInstallDropletDirectory(c *C) {
+	err := s.container.InstallDroplet(context.Background(), s.dropletDirectory)
+
+	c.Assert(err, IsNil)
+	c.Assert(s.copyInCalls, DeepEquals, [][]string{{"the_warden_handle", s.dropletDirectory + "/", "/home/vcap/app/"}})
+	c.Assert(s.scripts, DeepEquals, []string{"chown -R vcap:vcap /home/vcap/app"})
+}
+
+func (s *FilesSuite) TestInstallMissingDroplet(c *C) {
+	err := s.container.InstallDroplet(context.Background(), filepath.Join(s.dropletDirectory, "missing.tgz"))
+
+	c.Assert(err, NotNil)
+	c.Assert(s.copyInCalls, IsNil)
+}
+
+func (s *FilesSuite) TestInstallDropletCopyInError(c *C) {
+	tarball := filepath.Join(s.dropletDirectory, "droplet.tgz")
+	c.Assert(ioutil.WriteFile(tarball, []byte("droplet"), 0644), IsNil)
+	s.fakeClient.CopyInFunc = func(string, string, string) (*warden.CopyInResponse, error) {
+		return nil, errors.New("failed to copy in")
+	}
+
+	err := s.container.InstallDroplet(context.Background(), tarball)
+
+	c.Assert(err.Error(), Equals, "failed to copy in")
+	c.Assert(s.scripts, IsNil)
+}
+
+func (s *FilesSuite) TestCopyOut(c *C) {
+	var calls [][]string
+	s.fakeClient.CopyOutFunc = func(handle, srcPath, dstPath, owner string) (*warden.CopyOutResponse, error) {
+		calls = append(calls, []string{handle, srcPath, dstPath, owner})
+		return &warden.CopyOutResponse{}, nil
+	}
+
+	err := s.container.CopyOut(context.Background(), "/home/vcap/app/report.csv", "/tmp/report.csv")
+
+	c.Assert(err, IsNil)
+	c.Assert(calls, DeepEquals, [][]string{{"the_warden_handle", "/home/vcap/app/report.csv", "/tmp/report.csv", ""}})
+}
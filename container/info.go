@@ -0,0 +1,101 @@
+package container
+
+import (
+	"context"
+	warden "github.com/cloudfoundry/gordon"
+)
+
+type ContainerInspector interface {
+	Connect(ctx context.Context) error
+	Attach(ctx context.Context, handle string) error
+	Info(ctx context.Context) (*ContainerInfo, error)
+}
+
+type ContainerInfo struct {
+	Handle      string         `json:"handle"`
+	State       string         `json:"state"`
+	Events      []string       `json:"events,omitempty"`
+	HostIp      string         `json:"host_ip,omitempty"`
+	ContainerIp string         `json:"container_ip,omitempty"`
+	Memory      MemoryUsage    `json:"memory"`
+	Disk        DiskUsage      `json:"disk"`
+	Cpu         CpuUsage       `json:"cpu"`
+	Bandwidth   BandwidthStats `json:"bandwidth"`
+	JobIds      []uint64       `json:"job_ids"`
+}
+
+type MemoryUsage struct {
+	RssInBytes        uint64 `json:"rss_in_bytes"`
+	CacheInBytes      uint64 `json:"cache_in_bytes"`
+	TotalRssInBytes   uint64 `json:"total_rss_in_bytes"`
+	TotalCacheInBytes uint64 `json:"total_cache_in_bytes"`
+}
+
+type DiskUsage struct {
+	BytesUsed  uint64 `json:"bytes_used"`
+	InodesUsed uint64 `json:"inodes_used"`
+}
+
+type CpuUsage struct {
+	UsageInNanoseconds uint64 `json:"usage_in_nanoseconds"`
+	User               uint64 `json:"user"`
+	System             uint64 `json:"system"`
+}
+
+type BandwidthStats struct {
+	InRate   uint64 `json:"in_rate"`
+	InBurst  uint64 `json:"in_burst"`
+	OutRate  uint64 `json:"out_rate"`
+	OutBurst uint64 `json:"out_burst"`
+}
+
+func (c *Container) Info(ctx context.Context) (*ContainerInfo, error) {
+	var response *warden.InfoResponse
+	err := c.retrying(ctx, func() (err error) {
+		response, err = c.client.Info(c.handle)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	memory := response.GetMemoryStat()
+	disk := response.GetDiskStat()
+	cpu := response.GetCpuStat()
+	bandwidth := response.GetBandwidthStat()
+
+	jobIds := response.GetJobIds()
+	if jobIds == nil {
+		jobIds = []uint64{}
+	}
+
+	return &ContainerInfo{
+		Handle:      c.handle,
+		State:       response.GetState(),
+		Events:      response.GetEvents(),
+		HostIp:      response.GetHostIp(),
+		ContainerIp: response.GetContainerIp(),
+		Memory: MemoryUsage{
+			RssInBytes:        memory.GetRss(),
+			CacheInBytes:      memory.GetCache(),
+			TotalRssInBytes:   memory.GetTotalRss(),
+			TotalCacheInBytes: memory.GetTotalCache(),
+		},
+		Disk: DiskUsage{
+			BytesUsed:  disk.GetBytesUsed(),
+			InodesUsed: disk.GetInodesUsed(),
+		},
+		Cpu: CpuUsage{
+			UsageInNanoseconds: cpu.GetUsage(),
+			User:               cpu.GetUser(),
+			System:             cpu.GetSystem(),
+		},
+		Bandwidth: BandwidthStats{
+			InRate:   bandwidth.GetInRate(),
+			InBurst:  bandwidth.GetInBurst(),
+			OutRate:  bandwidth.GetOutRate(),
+			OutBurst: bandwidth.GetOutBurst(),
+		},
+		JobIds: jobIds,
+	}, nil
+}
@@ -0,0 +1,150 @@
+package container
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	warden "github.com/cloudfoundry/gordon"
+	. "launchpad.net/gocheck"
+)
+
+type InfoSuite struct {
+}
+
+func init() {
+	Suite(&InfoSuite{})
+}
+
+func uint64Pointer(value uint64) *uint64 {
+	return &value
+}
+
+func (s *InfoSuite) TestInfo(c *C) {
+	var infoHandle string
+	state, hostIp := "active", "10.0.0.1"
+	fakeClient := MakeFakeWardenClient()
+	fakeClient.InfoFunc = func(handle string) (*warden.InfoResponse, error) {
+		infoHandle = handle
+		return &warden.InfoResponse{
+			State:  &state,
+			Events: []string{"out of memory"},
+			HostIp: &hostIp,
+			MemoryStat: &warden.InfoResponse_MemoryStat{
+				Rss:        uint64Pointer(1024),
+				Cache:      uint64Pointer(512),
+				TotalRss:   uint64Pointer(2048),
+				TotalCache: uint64Pointer(1024),
+			},
+			DiskStat: &warden.InfoResponse_DiskStat{
+				BytesUsed:  uint64Pointer(4096),
+				InodesUsed: uint64Pointer(12),
+			},
+			CpuStat: &warden.InfoResponse_CpuStat{
+				Usage:  uint64Pointer(1000000),
+				User:   uint64Pointer(30),
+				System: uint64Pointer(20),
+			},
+			BandwidthStat: &warden.InfoResponse_BandwidthStat{
+				InRate:   uint64Pointer(100),
+				InBurst:  uint64Pointer(200),
+				OutRate:  uint64Pointer(300),
+				OutBurst: uint64Pointer(400),
+			},
+			JobIds: []uint64{1, 2},
+		}, nil
+	}
+
+	container := NewContainer(fakeClient)
+	container.handle = "the_warden_handle"
+
+	info, err := container.Info(context.Background())
+
+	c.Assert(err, IsNil)
+	c.Assert(infoHandle, Equals, "the_warden_handle")
+	c.Assert(info, DeepEquals, &ContainerInfo{
+		Handle: "the_warden_handle",
+		State:  "active",
+		Events: []string{"out of memory"},
+		HostIp: "10.0.0.1",
+		Memory: MemoryUsage{
+			RssInBytes:        1024,
+			CacheInBytes:      512,
+			TotalRssInBytes:   2048,
+			TotalCacheInBytes: 1024,
+		},
+		Disk:      DiskUsage{BytesUsed: 4096, InodesUsed: 12},
+		Cpu:       CpuUsage{UsageInNanoseconds: 1000000, User: 30, System: 20},
+		Bandwidth: BandwidthStats{InRate: 100, InBurst: 200, OutRate: 300, OutBurst: 400},
+		JobIds:    []uint64{1, 2},
+	})
+}
+
+func (s *InfoSuite) TestInfoWithoutStats(c *C) {
+	container := NewContainer(MakeFakeWardenClient())
+	container.handle = "the_warden_handle"
+
+	info, err := container.Info(context.Background())
+	c.Assert(err, IsNil)
+
+	output, err := json.Marshal(info)
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Equals, `{"handle":"the_warden_handle","state":"",`+
+		`"memory":{"rss_in_bytes":0,"cache_in_bytes":0,"total_rss_in_bytes":0,"total_cache_in_bytes":0},`+
+		`"disk":{"bytes_used":0,"inodes_used":0},`+
+		`"cpu":{"usage_in_nanoseconds":0,"user":0,"system":0},`+
+		`"bandwidth":{"in_rate":0,"in_burst":0,"out_rate":0,"out_burst":0},`+
+		`"job_ids":[]}`)
+}
+
+func (s *InfoSuite) TestInfoError(c *C) {
+	fakeClient := MakeFakeWardenClient()
+	fakeClient.InfoFunc = func(string) (*warden.InfoResponse, error) {
+		return nil, errors.New("unknown handle")
+	}
+
+	info, err := NewContainer(fakeClient).Info(context.Background())
+
+	c.Assert(info, IsNil)
+	c.Assert(err.Error(), Equals, "unknown handle")
+}
+
+func (s *InfoSuite) TestStatusRequiresHandle(c *C) {
+	info, err := Status(context.Background(), `{"warden_socket_path": "/tmp/warden.sock"}`, nil)
+
+	c.Assert(info, IsNil)
+	c.Assert(err.Error(), Equals, "invalid input: handle is required")
+}
+
+func (s *InfoSuite) TestStatusRequiresTheWardenBackend(c *C) {
+	info, err := Status(context.Background(), `{"backend": "local", "warden_socket_path": "/tmp/warden.sock", "handle": "/tmp/container-1"}`, nil)
+
+	c.Assert(info, IsNil)
+	c.Assert(err.Error(), Equals, "invalid input: status is not supported by the local backend")
+}
+
+type FakeInspectingContainer struct {
+	*FakeContainer
+	FakeInfo *ContainerInfo
+}
+
+func (c *FakeInspectingContainer) Info(ctx context.Context) (*ContainerInfo, error) {
+	return c.FakeInfo, nil
+}
+
+func (s *InfoSuite) TestStatusUsesTheSelectedBackend(c *C) {
+	fakeContainer := &FakeInspectingContainer{
+		FakeContainer: &FakeContainer{},
+		FakeInfo:      &ContainerInfo{Handle: "container-1", State: "active"},
+	}
+	RegisterBackend("fake", func(*CommandLineJson) (ContainerCreator, error) {
+		return fakeContainer, nil
+	})
+	defer delete(backends, "fake")
+
+	info, err := Status(context.Background(), `{"backend": "fake", "handle": "container-1"}`, nil)
+
+	c.Assert(err, IsNil)
+	c.Assert(info, Equals, fakeContainer.FakeInfo)
+	c.Assert(fakeContainer.ConnectCalls, Equals, 1)
+	c.Assert(fakeContainer.AttachCalls, DeepEquals, []string{"container-1"})
+}
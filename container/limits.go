@@ -0,0 +1,95 @@
+package container
+
+import (
+	"fmt"
+	"math"
+)
+
+const bytesPerMegabyte = 1024 * 1024
+
+func (c *CommandLineJson) ApplyNatsLimits() error {
+	if c.Task == nil {
+		return nil
+	}
+	if c.Task.Environment == nil {
+		if c.Debug != "" {
+			return &ValidationError{Problems: []string{"debug requires task.environment to export the debug port to the task"}}
+		}
+		return nil
+	}
+	natsLimits := &c.Task.Environment.NatsData.Limits
+
+	var problems []string
+	problems = append(problems, reconcileString("debug", &c.Debug,
+		"task.environment.nats_data.debug", &c.Task.Environment.NatsData.Debug)...)
+	problems = append(problems, reconcileMegabytes("memory_limit_in_bytes", &c.MemoryLimitInBytes,
+		"task.environment.nats_data.limits.mem", &natsLimits.Mem)...)
+	problems = append(problems, reconcileMegabytes("disk_limit_in_bytes", &c.DiskLimitInBytes,
+		"task.environment.nats_data.limits.disk", &natsLimits.Disk)...)
+	problems = append(problems, reconcileCount("file_descriptor_limit", &c.FileDescriptorLimit,
+		"task.environment.nats_data.limits.fds", &natsLimits.Fds)...)
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+func reconcileMegabytes(bytesField string, bytes *uint64, megabytesField string, megabytes *int) []string {
+	if *megabytes < 0 {
+		return []string{fmt.Sprintf("%s must not be negative", megabytesField)}
+	}
+	if uint64(*megabytes) > math.MaxUint64/bytesPerMegabyte {
+		return []string{fmt.Sprintf("%s %d is too large to convert to bytes", megabytesField, *megabytes)}
+	}
+	natsBytes := uint64(*megabytes) * bytesPerMegabyte
+
+	switch {
+	case *megabytes == 0 && *bytes == 0:
+	case *megabytes == 0:
+		if *bytes%bytesPerMegabyte != 0 {
+			return []string{fmt.Sprintf("%s %d must be a whole number of megabytes to set %s", bytesField, *bytes, megabytesField)}
+		}
+		if *bytes/bytesPerMegabyte > math.MaxInt32 {
+			return []string{fmt.Sprintf("%s %d is too large to set %s", bytesField, *bytes, megabytesField)}
+		}
+		*megabytes = int(*bytes / bytesPerMegabyte)
+	case *bytes == 0:
+		*bytes = natsBytes
+	case *bytes != natsBytes:
+		return []string{fmt.Sprintf("%s %d disagrees with %s %d (%d bytes)", bytesField, *bytes, megabytesField, *megabytes, natsBytes)}
+	}
+	return nil
+}
+
+func reconcileCount(field string, count *uint64, natsField string, natsCount *int) []string {
+	if *natsCount < 0 {
+		return []string{fmt.Sprintf("%s must not be negative", natsField)}
+	}
+
+	switch {
+	case *natsCount == 0 && *count == 0:
+	case *natsCount == 0:
+		if *count > math.MaxInt32 {
+			return []string{fmt.Sprintf("%s %d is too large to set %s", field, *count, natsField)}
+		}
+		*natsCount = int(*count)
+	case *count == 0:
+		*count = uint64(*natsCount)
+	case *count != uint64(*natsCount):
+		return []string{fmt.Sprintf("%s %d disagrees with %s %d", field, *count, natsField, *natsCount)}
+	}
+	return nil
+}
+
+func reconcileString(field string, value *string, natsField string, natsValue *string) []string {
+	switch {
+	case *natsValue == "":
+		*natsValue = *value
+	case *value == "":
+		*value = *natsValue
+	case *value != *natsValue:
+		return []string{fmt.Sprintf("%s %q disagrees with %s %q", field, *value, natsField, *natsValue)}
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package container
+
+import (
+	"github.com/cloudfoundry/app_container_setup/parser"
+	. "launchpad.net/gocheck"
+	"math"
+)
+
+type LimitsSuite struct {
+}
+
+func init() {
+	Suite(&LimitsSuite{})
+}
+
+func inputWithNatsLimits(mem, disk, fds int) *CommandLineJson {
+	environment := &parser.InputJSON{}
+	environment.NatsData.Limits = parser.InputNatsLimitsJSON{Mem: mem, Disk: disk, Fds: fds}
+	return &CommandLineJson{Task: &TaskJson{Command: "true", Environment: environment}}
+}
+
+func (s *LimitsSuite) TestWithoutTaskEnvironment(c *C) {
+	input := &CommandLineJson{MemoryLimitInBytes: 123}
+	c.Assert(input.ApplyNatsLimits(), IsNil)
+	c.Assert(input.MemoryLimitInBytes, Equals, uint64(123))
+}
+
+func (s *LimitsSuite) TestDerivesByteLimitsFromNatsLimits(c *C) {
+	input := inputWithNatsLimits(256, 1024, 16384)
+
+	c.Assert(input.ApplyNatsLimits(), IsNil)
+	c.Assert(input.MemoryLimitInBytes, Equals, uint64(256*1024*1024))
+	c.Assert(input.DiskLimitInBytes, Equals, uint64(1024*1024*1024))
+	c.Assert(input.FileDescriptorLimit, Equals, uint64(16384))
+}
+
+func (s *LimitsSuite) TestDerivesNatsLimitsFromByteLimits(c *C) {
+	input := inputWithNatsLimits(0, 0, 0)
+	input.MemoryLimitInBytes = 512 * 1024 * 1024
+	input.DiskLimitInBytes = 2048 * 1024 * 1024
+	input.FileDescriptorLimit = 1024
+
+	c.Assert(input.ApplyNatsLimits(), IsNil)
+	limits := input.Task.Environment.NatsData.Limits
+	c.Assert(limits, Equals, parser.InputNatsLimitsJSON{Mem: 512, Disk: 2048, Fds: 1024})
+}
+
+func (s *LimitsSuite) TestAgreeingLimits(c *C) {
+	input := inputWithNatsLimits(256, 1024, 100)
+	input.MemoryLimitInBytes = 256 * 1024 * 1024
+	input.DiskLimitInBytes = 1024 * 1024 * 1024
+	input.FileDescriptorLimit = 100
+
+	c.Assert(input.ApplyNatsLimits(), IsNil)
+}
+
+func (s *LimitsSuite) TestDisagreeingLimits(c *C) {
+	input := inputWithNatsLimits(256, 1024, 100)
+	input.MemoryLimitInBytes = 128 * 1024 * 1024
+	input.DiskLimitInBytes = 1
+	input.FileDescriptorLimit = 200
+
+	err := input.ApplyNatsLimits()
+
+	c.Assert(err.(*ValidationError).Problems, DeepEquals, []string{
+		"memory_limit_in_bytes 134217728 disagrees with task.environment.nats_data.limits.mem 256 (268435456 bytes)",
+		"disk_limit_in_bytes 1 disagrees with task.environment.nats_data.limits.disk 1024 (1073741824 bytes)",
+		"file_descriptor_limit 200 disagrees with task.environment.nats_data.limits.fds 100",
+	})
+}
+
+func (s *LimitsSuite) TestInvalidNatsLimits(c *C) {
+	input := inputWithNatsLimits(-1, 0, -1)
+	input.DiskLimitInBytes = 1000
+
+	err := input.ApplyNatsLimits()
+
+	c.Assert(err.(*ValidationError).Problems, DeepEquals, []string{
+		"task.environment.nats_data.limits.mem must not be negative",
+		"disk_limit_in_bytes 1000 must be a whole number of megabytes to set task.environment.nats_data.limits.disk",
+		"task.environment.nats_data.limits.fds must not be negative",
+	})
+}
+
+func (s *LimitsSuite) TestOverflowingNatsLimits(c *C) {
+	input := inputWithNatsLimits(math.MaxInt64, 0, 0)
+
+	err := input.ApplyNatsLimits()
+
+	c.Assert(err, ErrorMatches, "task.environment.nats_data.limits.mem 9223372036854775807 is too large to convert to bytes")
+}
+
+func (s *LimitsSuite) TestDerivesDebugFromNatsData(c *C) {
+	input := inputWithNatsLimits(0, 0, 0)
+	input.Task.Environment.NatsData.Debug = "suspend"
+
+	c.Assert(input.ApplyNatsLimits(), IsNil)
+	c.Assert(input.Debug, Equals, "suspend")
+}
+
+func (s *LimitsSuite) TestDerivesNatsDebugFromDebug(c *C) {
+	input := inputWithNatsLimits(0, 0, 0)
+	input.Debug = "run"
+
+	c.Assert(input.ApplyNatsLimits(), IsNil)
+	c.Assert(input.Task.Environment.NatsData.Debug, Equals, "run")
+}
+
+func (s *LimitsSuite) TestDisagreeingDebug(c *C) {
+	input := inputWithNatsLimits(0, 0, 0)
+	input.Debug = "run"
+	input.Task.Environment.NatsData.Debug = "suspend"
+
+	err := input.ApplyNatsLimits()
+
+	c.Assert(err, ErrorMatches, `.*debug "run" disagrees with task.environment.nats_data.debug "suspend"`)
+}
+
+func (s *LimitsSuite) TestDebugWithoutTaskEnvironment(c *C) {
+	input := &CommandLineJson{Debug: "run", Task: &TaskJson{Command: "true"}}
+
+	err := input.ApplyNatsLimits()
+
+	c.Assert(err, ErrorMatches, ".*debug requires task.environment to export the debug port to the task")
+}
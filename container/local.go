@@ -0,0 +1,365 @@
+package container
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	warden "github.com/cloudfoundry/gordon"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+const localHandlePrefix = "container-"
+
+type LocalContainer struct {
+	root                string
+	handle              string
+	fileDescriptorLimit uint64
+
+	lock      sync.Mutex
+	jobs      map[uint32]*localJob
+	lastJobId uint32
+}
+
+type localJob struct {
+	cmd    *exec.Cmd
+	stdout *localOutput
+	stderr *localOutput
+	done   chan struct{}
+	err    error
+}
+
+type localOutput struct {
+	lock   sync.Mutex
+	buffer bytes.Buffer
+	target io.Writer
+}
+
+func (o *localOutput) Write(data []byte) (int, error) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	if o.target != nil {
+		return o.target.Write(data)
+	}
+	return o.buffer.Write(data)
+}
+
+func (o *localOutput) attach(target io.Writer) error {
+	if target == nil {
+		return nil
+	}
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	_, err := o.buffer.WriteTo(target)
+	o.target = target
+	return err
+}
+
+func NewLocalContainer(root string) *LocalContainer {
+	if root == "" {
+		root = os.TempDir()
+	}
+	return &LocalContainer{root: root, jobs: map[uint32]*localJob{}}
+}
+
+func (c *LocalContainer) Handle() string {
+	return c.handle
+}
+
+func (c *LocalContainer) Connect(ctx context.Context) error {
+	return nil
+}
+
+func (c *LocalContainer) Create(ctx context.Context, spec *ContainerSpec) error {
+	handle, err := ioutil.TempDir(c.root, localHandlePrefix)
+	if err != nil {
+		return err
+	}
+	c.handle = handle
+
+	for _, bindMount := range spec.BindMounts {
+		if strings.ToLower(bindMount.Origin) == "container" {
+			continue
+		}
+		err = c.bind(ctx, bindMount)
+		if err != nil {
+			os.RemoveAll(handle)
+			c.handle = ""
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *LocalContainer) bind(ctx context.Context, bindMount *BindMount) error {
+	mode, err := bindMount.wardenMode()
+	if err != nil {
+		return err
+	}
+	dstPath := c.path(bindMount.DstPath)
+	err = os.MkdirAll(filepath.Dir(dstPath), 0755)
+	if err != nil {
+		return err
+	}
+
+	if mode == warden.CreateRequest_BindMount_RO {
+		return runLocal(ctx, "cp", "-RL", bindMount.SrcPath, dstPath)
+	}
+	return os.Symlink(bindMount.SrcPath, dstPath)
+}
+
+func (c *LocalContainer) Attach(ctx context.Context, handle string) error {
+	if filepath.Dir(filepath.Clean(handle)) != filepath.Clean(c.root) || !strings.HasPrefix(filepath.Base(handle), localHandlePrefix) {
+		return fmt.Errorf("container %s is not a %s directory in %s", handle, localHandlePrefix, c.root)
+	}
+	info, err := os.Lstat(handle)
+	if err != nil || !info.IsDir() {
+		return fmt.Errorf("container %s not found", handle)
+	}
+	c.handle = handle
+	return nil
+}
+
+func (c *LocalContainer) AllowEgress(ctx context.Context, rules []*EgressRule) error {
+	return nil
+}
+
+func (c *LocalContainer) SetDiskQuota(ctx context.Context, quota DiskQuota) (*DiskQuota, error) {
+	return &DiskQuota{}, nil
+}
+
+func (c *LocalContainer) SetMemoryLimit(ctx context.Context, limitInBytes uint64) (uint64, error) {
+	return 0, nil
+}
+
+func (c *LocalContainer) SetFileDescriptorLimit(limit uint64) error {
+	if limit == 0 {
+		return errors.New("file descriptor limit must be greater than zero")
+	}
+	c.fileDescriptorLimit = limit
+	return nil
+}
+
+func (c *LocalContainer) SetCpuLimit(ctx context.Context, limitInShares uint64) error {
+	return nil
+}
+
+func (c *LocalContainer) SetBandwidthLimit(ctx context.Context, rateInBytesPerSecond, burstInBytes uint64) error {
+	return nil
+}
+
+func (c *LocalContainer) ConfigureApplicationPorts(ctx context.Context) (*PortMapping, error) {
+	return c.mapPort()
+}
+
+func (c *LocalContainer) ConfigureConsolePorts(ctx context.Context) (*PortMapping, error) {
+	return c.mapPort()
+}
+
+func (c *LocalContainer) ConfigureDebugPorts(ctx context.Context) (*PortMapping, error) {
+	return c.mapPort()
+}
+
+func (c *LocalContainer) mapPort() (*PortMapping, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	defer listener.Close()
+
+	port := uint32(listener.Addr().(*net.TCPAddr).Port)
+	return &PortMapping{HostPort: port, ContainerPort: port}, nil
+}
+
+func (c *LocalContainer) ConfigureHomeDirectory(ctx context.Context) error {
+	for _, directory := range []string{appDirectoryPath, homeDirectoryPath + "/logs"} {
+		err := os.MkdirAll(c.path(directory), 0755)
+		if err != nil {
+			return err
+		}
+	}
+
+	tmp := c.path(homeDirectoryPath + "/tmp")
+	err := os.MkdirAll(tmp, 0755)
+	if err != nil {
+		return err
+	}
+	return os.Chmod(tmp, 01777)
+}
+
+func (c *LocalContainer) InstallDroplet(ctx context.Context, path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	if info.IsDir() {
+		return runLocal(ctx, "cp", "-R", filepath.Clean(path)+"/.", c.path(appDirectoryPath))
+	}
+	return runLocal(ctx, "tar", "-C", c.path(appDirectoryPath), "-xzf", path)
+}
+
+func (c *LocalContainer) SpawnTask(ctx context.Context, environmentScript string, command string) (uint32, error) {
+	scriptPath := c.path(environmentScriptPath)
+	err := ioutil.WriteFile(scriptPath, []byte(environmentScript), 0644)
+	if err != nil {
+		return 0, err
+	}
+
+	script := append(c.ulimits(), fmt.Sprintf("cd %s && source %s && %s", shellQuote(c.handle), shellQuote(scriptPath), command))
+	job := &localJob{
+		cmd:    exec.Command("bash", "-c", strings.Join(script, " && ")),
+		stdout: &localOutput{},
+		stderr: &localOutput{},
+		done:   make(chan struct{}),
+	}
+	job.cmd.Stdout = job.stdout
+	job.cmd.Stderr = job.stderr
+	job.cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	err = job.cmd.Start()
+	if err != nil {
+		return 0, err
+	}
+	go func() {
+		job.err = job.cmd.Wait()
+		close(job.done)
+	}()
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.lastJobId++
+	c.jobs[c.lastJobId] = job
+	return c.lastJobId, nil
+}
+
+func (c *LocalContainer) ulimits() []string {
+	var limits []string
+	if c.fileDescriptorLimit != 0 {
+		limits = append(limits, fmt.Sprintf("ulimit -n %d", c.fileDescriptorLimit))
+	}
+	return limits
+}
+
+func (c *LocalContainer) LinkTask(ctx context.Context, jobId uint32) (uint32, error) {
+	job, err := c.job(jobId)
+	if err != nil {
+		return 0, err
+	}
+
+	select {
+	case <-job.done:
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+
+	exitErr, ok := job.err.(*exec.ExitError)
+	if !ok {
+		return 0, job.err
+	}
+	status := exitErr.Sys().(syscall.WaitStatus)
+	if status.Signaled() {
+		return 128 + uint32(status.Signal()), nil
+	}
+	return uint32(status.ExitStatus()), nil
+}
+
+func (c *LocalContainer) StreamTask(ctx context.Context, jobId uint32, stdout, stderr io.Writer) (uint32, error) {
+	job, err := c.job(jobId)
+	if err != nil {
+		return 0, err
+	}
+
+	err = job.stdout.attach(stdout)
+	if err != nil {
+		return 0, err
+	}
+	err = job.stderr.attach(stderr)
+	if err != nil {
+		return 0, err
+	}
+	return c.LinkTask(ctx, jobId)
+}
+
+func (c *LocalContainer) job(jobId uint32) (*localJob, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	job, ok := c.jobs[jobId]
+	if !ok {
+		return nil, fmt.Errorf("unknown job %d", jobId)
+	}
+	return job, nil
+}
+
+func (c *LocalContainer) CopyOut(ctx context.Context, srcPath, dstPath string) error {
+	return runLocal(ctx, "cp", "-R", c.path(srcPath), dstPath)
+}
+
+func (c *LocalContainer) OutOfMemory(ctx context.Context) (bool, error) {
+	return false, nil
+}
+
+func (c *LocalContainer) Stop(ctx context.Context) error {
+	return c.signal(syscall.SIGTERM)
+}
+
+func (c *LocalContainer) Kill(ctx context.Context) error {
+	return c.signal(syscall.SIGKILL)
+}
+
+func (c *LocalContainer) signal(signal syscall.Signal) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, job := range c.jobs {
+		select {
+		case <-job.done:
+			continue
+		default:
+		}
+		err := syscall.Kill(-job.cmd.Process.Pid, signal)
+		if err != nil && err != syscall.ESRCH {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *LocalContainer) Destroy(ctx context.Context) error {
+	if c.handle == "" {
+		return nil
+	}
+	err := c.Kill(ctx)
+	if err != nil {
+		return err
+	}
+	err = os.RemoveAll(c.handle)
+	if err != nil {
+		return err
+	}
+	c.handle = ""
+	return nil
+}
+
+func (c *LocalContainer) path(containerPath string) string {
+	return filepath.Join(c.handle, strings.TrimPrefix(containerPath, homeDirectoryPath))
+}
+
+func shellQuote(value string) string {
+	return "'" + strings.Replace(value, "'", `'\''`, -1) + "'"
+}
+
+func runLocal(ctx context.Context, name string, args ...string) error {
+	output, err := exec.CommandContext(ctx, name, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s failed: %s: %s", name, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
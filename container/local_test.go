@@ -0,0 +1,195 @@
+package container
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	. "launchpad.net/gocheck"
+	"os"
+	"path/filepath"
+)
+
+type LocalSuite struct {
+	root      string
+	container *LocalContainer
+}
+
+func init() {
+	Suite(&LocalSuite{})
+}
+
+func (s *LocalSuite) SetUpTest(c *C) {
+	var err error
+	s.root, err = ioutil.TempDir("", "local_container")
+	c.Assert(err, IsNil)
+
+	s.container = NewLocalContainer(s.root)
+	c.Assert(s.container.Create(context.Background(), &ContainerSpec{}), IsNil)
+	c.Assert(s.container.ConfigureHomeDirectory(context.Background()), IsNil)
+}
+
+func (s *LocalSuite) TearDownTest(c *C) {
+	s.container.Destroy(context.Background())
+	os.RemoveAll(s.root)
+}
+
+func (s *LocalSuite) TestCreateAndDestroy(c *C) {
+	handle := s.container.Handle()
+	c.Assert(filepath.Dir(handle), Equals, s.root)
+
+	for _, directory := range []string{"app", "logs", "tmp"} {
+		info, err := os.Stat(filepath.Join(handle, directory))
+		c.Assert(err, IsNil)
+		c.Assert(info.IsDir(), Equals, true)
+	}
+
+	c.Assert(s.container.Destroy(context.Background()), IsNil)
+	c.Assert(s.container.Handle(), Equals, "")
+	_, err := os.Stat(handle)
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+func (s *LocalSuite) TestCreateLinksHostBindMounts(c *C) {
+	container := NewLocalContainer(s.root)
+	err := container.Create(context.Background(), &ContainerSpec{BindMounts: []*BindMount{
+		{SrcPath: s.root, DstPath: "/home/vcap/data", Mode: "rw"},
+		{SrcPath: "/var/vcap/packages", DstPath: "/packages", Origin: "container"},
+	}})
+	c.Assert(err, IsNil)
+	defer container.Destroy(context.Background())
+
+	target, err := os.Readlink(filepath.Join(container.Handle(), "data"))
+	c.Assert(err, IsNil)
+	c.Assert(target, Equals, s.root)
+	_, err = os.Lstat(filepath.Join(container.Handle(), "packages"))
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+func (s *LocalSuite) TestCreateCopiesReadOnlyBindMounts(c *C) {
+	source := filepath.Join(s.root, "source")
+	c.Assert(os.Mkdir(source, 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(source, "file"), []byte("original\n"), 0644), IsNil)
+
+	container := NewLocalContainer(s.root)
+	err := container.Create(context.Background(), &ContainerSpec{BindMounts: []*BindMount{
+		{SrcPath: source, DstPath: "/home/vcap/ro", Mode: "ro"},
+	}})
+	c.Assert(err, IsNil)
+	defer container.Destroy(context.Background())
+
+	jobId, err := container.SpawnTask(context.Background(), "", "cat ro/file && echo changed > ro/file && rm ro/file")
+	c.Assert(err, IsNil)
+	stdout := &bytes.Buffer{}
+	exitStatus, err := container.StreamTask(context.Background(), jobId, stdout, nil)
+	c.Assert(err, IsNil)
+	c.Assert(exitStatus, Equals, uint32(0))
+	c.Assert(stdout.String(), Equals, "original\n")
+
+	contents, err := ioutil.ReadFile(filepath.Join(source, "file"))
+	c.Assert(err, IsNil)
+	c.Assert(string(contents), Equals, "original\n")
+}
+
+func (s *LocalSuite) TestAttach(c *C) {
+	container := NewLocalContainer(s.root)
+	c.Assert(container.Attach(context.Background(), s.container.Handle()), IsNil)
+	c.Assert(container.Handle(), Equals, s.container.Handle())
+
+	missing := filepath.Join(s.root, "container-missing")
+	err := container.Attach(context.Background(), missing)
+	c.Assert(err.Error(), Equals, "container "+missing+" not found")
+}
+
+func (s *LocalSuite) TestAttachRejectsDirectoriesItDidNotCreate(c *C) {
+	outside, err := ioutil.TempDir("", "container-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(outside)
+	unprefixed := filepath.Join(s.root, "data")
+	c.Assert(os.Mkdir(unprefixed, 0755), IsNil)
+
+	container := NewLocalContainer(s.root)
+	for _, handle := range []string{outside, unprefixed, s.root, filepath.Join(s.container.Handle(), "app")} {
+		err = container.Attach(context.Background(), handle)
+		c.Assert(err.Error(), Equals, "container "+handle+" is not a container- directory in "+s.root)
+		c.Assert(container.Destroy(context.Background()), IsNil)
+		_, err = os.Stat(handle)
+		c.Assert(err, IsNil)
+	}
+}
+
+func (s *LocalSuite) TestRunsTasksWithTheEnvironmentAndLimits(c *C) {
+	c.Assert(s.container.SetFileDescriptorLimit(256), IsNil)
+	applied, err := s.container.SetMemoryLimit(context.Background(), 256*1024*1024)
+	c.Assert(err, IsNil)
+	c.Assert(applied, Equals, uint64(0))
+	jobId, err := s.container.SpawnTask(context.Background(), "export GREETING=hello\n",
+		`echo $GREETING from $(basename $PWD); ulimit -n; ulimit -v; echo oops >&2; exit 3`)
+	c.Assert(err, IsNil)
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	exitStatus, err := s.container.StreamTask(context.Background(), jobId, stdout, stderr)
+
+	c.Assert(err, IsNil)
+	c.Assert(exitStatus, Equals, uint32(3))
+	c.Assert(stdout.String(), Equals, "hello from "+filepath.Base(s.container.Handle())+"\n256\nunlimited\n")
+	c.Assert(stderr.String(), Equals, "oops\n")
+}
+
+func (s *LocalSuite) TestRunsTasksUnderARootWithSpacesAndQuotes(c *C) {
+	root := filepath.Join(s.root, "local root's")
+	c.Assert(os.Mkdir(root, 0755), IsNil)
+	container := NewLocalContainer(root)
+	c.Assert(container.Create(context.Background(), &ContainerSpec{}), IsNil)
+	defer container.Destroy(context.Background())
+	c.Assert(container.ConfigureHomeDirectory(context.Background()), IsNil)
+
+	jobId, err := container.SpawnTask(context.Background(), "export GREETING=hello\n", `echo $GREETING from "$PWD"`)
+	c.Assert(err, IsNil)
+	stdout := &bytes.Buffer{}
+	exitStatus, err := container.StreamTask(context.Background(), jobId, stdout, nil)
+
+	c.Assert(err, IsNil)
+	c.Assert(exitStatus, Equals, uint32(0))
+	c.Assert(stdout.String(), Equals, "hello from "+container.Handle()+"\n")
+}
+
+func (s *LocalSuite) TestKillStopsRunningTasks(c *C) {
+	jobId, err := s.container.SpawnTask(context.Background(), "", "sleep 60")
+	c.Assert(err, IsNil)
+
+	c.Assert(s.container.Kill(context.Background()), IsNil)
+	exitStatus, err := s.container.LinkTask(context.Background(), jobId)
+
+	c.Assert(err, IsNil)
+	c.Assert(exitStatus, Equals, uint32(137))
+}
+
+func (s *LocalSuite) TestLinkUnknownJob(c *C) {
+	_, err := s.container.LinkTask(context.Background(), 99)
+	c.Assert(err.Error(), Equals, "unknown job 99")
+}
+
+func (s *LocalSuite) TestInstallDropletAndCopyOut(c *C) {
+	droplet := filepath.Join(s.root, "droplet")
+	c.Assert(os.MkdirAll(filepath.Join(droplet, "config"), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(droplet, "config", "app.yml"), []byte("name: app\n"), 0644), IsNil)
+
+	err := s.container.InstallDroplet(context.Background(), droplet)
+	c.Assert(err, IsNil)
+
+	output := filepath.Join(s.root, "app.yml")
+	err = s.container.CopyOut(context.Background(), "/home/vcap/app/config/app.yml", output)
+	c.Assert(err, IsNil)
+
+	contents, err := ioutil.ReadFile(output)
+	c.Assert(err, IsNil)
+	c.Assert(string(contents), Equals, "name: app\n")
+}
+
+func (s *LocalSuite) TestPortsAreFreeHostPorts(c *C) {
+	mapping, err := s.container.ConfigureApplicationPorts(context.Background())
+
+	c.Assert(err, IsNil)
+	c.Assert(mapping.HostPort, Not(Equals), uint32(0))
+	c.Assert(mapping.ContainerPort, Equals, mapping.HostPort)
+}
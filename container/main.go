@@ -1,51 +1,570 @@
 package container
 
 import (
+	"context"
 	"encoding/json"
-	warden "github.com/cloudfoundry/gordon"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type CommandLineJson struct {
-	DiskLimitInBytes   uint64       `json:"disk_limit_in_bytes",required`
-	MemoryLimitInBytes uint64       `json:"memory_limit_in_bytes"`
-	BindMounts         []*BindMount `json:"bind_mounts"`
-	WardenSocketPath   string       `json:"warden_socket_path"`
+	DiskLimitInBytes              uint64        `json:"disk_limit_in_bytes"`
+	DiskSoftLimitInBytes          uint64        `json:"disk_soft_limit_in_bytes"`
+	DiskInodeLimit                uint64        `json:"disk_inode_limit"`
+	DiskInodeSoftLimit            uint64        `json:"disk_inode_soft_limit"`
+	MemoryLimitInBytes            uint64        `json:"memory_limit_in_bytes"`
+	FileDescriptorLimit           uint64        `json:"file_descriptor_limit"`
+	CpuLimitInShares              uint64        `json:"cpu_limit_in_shares"`
+	BandwidthRateInBytesPerSecond uint64        `json:"bandwidth_rate_in_bytes_per_second"`
+	BandwidthBurstInBytes         uint64        `json:"bandwidth_burst_in_bytes"`
+	BindMounts                    []*BindMount  `json:"bind_mounts"`
+	EgressRules                   []*EgressRule `json:"egress_rules"`
+	DropletPath                   string        `json:"droplet_path"`
+	Artifacts                     []*Artifact   `json:"artifacts"`
+	AllowBoundServices            bool          `json:"allow_bound_services"`
+	WardenSocketPath              string        `json:"warden_socket_path"`
+	WardenRetries                 *RetryJson    `json:"warden_retries"`
+	Handle                        string        `json:"handle"`
+	GraceTimeInSeconds            uint32        `json:"grace_time_in_seconds"`
+	Backend                       string        `json:"backend"`
+	LocalRoot                     string        `json:"local_root"`
+	Debug                         string        `json:"debug"`
+	Task                          *TaskJson     `json:"task"`
+}
+
+type StepError struct {
+	Step        string
+	Err         error
+	RollbackErr error
+}
+
+func (e *StepError) Error() string {
+	message := fmt.Sprintf("%s failed: %s", e.Step, e.Err)
+	if e.RollbackErr != nil {
+		message = fmt.Sprintf("%s (destroying container also failed: %s)", message, e.RollbackErr)
+	}
+	return message
+}
+
+type TaskTimeoutError struct {
+	MaxDuration time.Duration
+}
+
+func (e *TaskTimeoutError) Error() string {
+	return fmt.Sprintf("task did not finish within %s", e.MaxDuration)
+}
+
+const DefaultCleanupTimeout = 30 * time.Second
+
+type InputError struct {
+	Err error
+}
+
+func (e *InputError) Error() string {
+	return fmt.Sprintf("invalid input: %s", e.Err)
+}
+
+type Options struct {
+	Strict      bool
+	TaskStdout  io.Writer
+	TaskStderr  io.Writer
+	GracePeriod time.Duration
 }
 
 type State struct {
 	Container       ContainerCreator
 	CommandLineJson *CommandLineJson
+	Result          *SetupResult
+	TaskStdout      io.Writer
+	TaskStderr      io.Writer
+	GracePeriod     time.Duration
+	CleanupTimeout  time.Duration
+	lookupIP        func(string) ([]net.IP, error)
 }
 
 func NewState(container ContainerCreator, commandLineJson *CommandLineJson) *State {
-	return &State{Container: container, CommandLineJson: commandLineJson}
+	return &State{
+		Container:       container,
+		CommandLineJson: commandLineJson,
+		Result:          NewSetupResult(),
+		CleanupTimeout:  DefaultCleanupTimeout,
+		lookupIP:        net.LookupIP,
+	}
 }
 
-func Main(inputJson string) (*State, error) {
-	commandLineJson, err := parseInput(inputJson)
+func Main(ctx context.Context, inputJson string, options *Options) (*State, error) {
+	state, err := Setup(inputJson, options)
+	if err != nil {
+		return nil, err
+	}
+	return state, state.Perform(ctx)
+}
+
+func Setup(inputJson string, options *Options) (*State, error) {
+	if options == nil {
+		options = &Options{}
+	}
+
+	commandLineJson, err := parseInput(inputJson, options.Strict)
+	if err != nil {
+		return nil, &InputError{Err: err}
+	}
+	natsErr := commandLineJson.ApplyNatsLimits()
+	err = joinValidationErrors(natsErr, commandLineJson.Validate())
+	if err != nil {
+		return nil, &InputError{Err: err}
+	}
 
-	connectionInfo := &warden.ConnectionInfo{commandLineJson.WardenSocketPath}
-	container := NewContainer(warden.NewClient(connectionInfo))
+	container, err := backends[commandLineJson.backend()](commandLineJson)
+	if err != nil {
+		return nil, err
+	}
 
-	state := &State{CommandLineJson: commandLineJson, Container: container}
-	//	state.Perform
-	return state, err
+	state := NewState(container, commandLineJson)
+	state.TaskStdout = options.TaskStdout
+	state.TaskStderr = options.TaskStderr
+	state.GracePeriod = options.GracePeriod
+	return state, nil
 }
 
-func parseInput(inputJson string) (*CommandLineJson, error) {
+func Status(ctx context.Context, inputJson string, options *Options) (*ContainerInfo, error) {
+	if options == nil {
+		options = &Options{}
+	}
+
+	commandLineJson, err := parseInput(inputJson, options.Strict)
+	if err != nil {
+		return nil, &InputError{Err: err}
+	}
+	err = commandLineJson.ValidateStatus()
+	if err != nil {
+		return nil, &InputError{Err: err}
+	}
+
+	creator, err := backends[commandLineJson.backend()](commandLineJson)
+	if err != nil {
+		return nil, err
+	}
+	container, ok := creator.(ContainerInspector)
+	if !ok {
+		problem := fmt.Sprintf("status is not supported by the %s backend", commandLineJson.backend())
+		return nil, &InputError{Err: &ValidationError{Problems: []string{problem}}}
+	}
+	err = container.Connect(ctx)
+	if err != nil {
+		return nil, &StepError{Step: "connect", Err: err}
+	}
+	err = container.Attach(ctx, commandLineJson.Handle)
+	if err != nil {
+		return nil, &StepError{Step: "attach", Err: err}
+	}
+	info, err := container.Info(ctx)
+	if err != nil {
+		return nil, &StepError{Step: "info", Err: err}
+	}
+	return info, nil
+}
+
+func (c *CommandLineJson) containerProperties() map[string]string {
+	if c.Task == nil {
+		return nil
+	}
+
+	properties := map[string]string{}
+	if c.Task.Id != "" {
+		properties[PropertyTaskId] = c.Task.Id
+	}
+	if environment := c.Task.Environment; environment != nil {
+		properties[PropertyAppName] = environment.NatsData.Name
+		properties[PropertyAppVersion] = environment.NatsData.ApplicationVersion
+		properties[PropertyInstanceGuid] = environment.InstanceGuid
+		properties[PropertyInstanceIndex] = strconv.Itoa(environment.NatsData.Index)
+	}
+	return properties
+}
+
+func parseInput(inputJson string, strict bool) (*CommandLineJson, error) {
 	var input CommandLineJson
 	err := json.Unmarshal([]byte(inputJson), &input)
+	if err != nil {
+		return nil, describeJSONError(err)
+	}
+
+	if strict {
+		err = checkForUnknownKeys(inputJson, input)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &input, nil
+}
+
+func describeJSONError(err error) error {
+	switch err := err.(type) {
+	case *json.SyntaxError:
+		return fmt.Errorf("malformed JSON at offset %d: %s", err.Offset, err)
+	case *json.UnmarshalTypeError:
+		field := err.Field
+		if field == "" {
+			field = "input"
+		}
+		return fmt.Errorf("%s at offset %d must be %s, got JSON %s", field, err.Offset, err.Type, err.Value)
+	}
+	return err
+}
+
+func checkForUnknownKeys(inputJson string, input interface{}) error {
+	var keys map[string]json.RawMessage
+	err := json.Unmarshal([]byte(inputJson), &keys)
+	if err != nil {
+		return describeJSONError(err)
+	}
+
+	known := map[string]bool{}
+	inputType := reflect.TypeOf(input)
+	for i := 0; i < inputType.NumField(); i++ {
+		name := strings.Split(inputType.Field(i).Tag.Get("json"), ",")[0]
+		known[name] = true
+	}
+
+	var unknown []string
+	for key := range keys {
+		if !known[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("unknown keys: %s", strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+type step struct {
+	name string
+	run  func(context.Context) error
+}
+
+func (s *State) Perform(ctx context.Context) error {
+	err := s.runStep(ctx, step{"connect", s.Container.Connect})
+	if err != nil {
+		return &StepError{Step: "connect", Err: err}
+	}
+
+	first := step{"create", s.create}
+	if s.attached() {
+		first = step{"attach", s.attach}
+	}
+	err = s.runStep(ctx, first)
+	if err != nil {
+		return &StepError{Step: first.name, Err: err}
+	}
+	s.Result.Handle = s.Container.Handle()
+
+	for _, step := range s.steps() {
+		err = s.runStep(ctx, step)
+		if err != nil {
+			return s.rollback(&StepError{Step: step.name, Err: err})
+		}
+	}
+	return nil
+}
+
+func (s *State) runStep(ctx context.Context, step step) error {
+	started := time.Now()
+	err := step.run(ctx)
+	s.Result.recordStep(step.name, started)
+	return err
+}
+
+func (s *State) create(ctx context.Context) error {
+	return s.Container.Create(ctx, &ContainerSpec{
+		BindMounts:         s.CommandLineJson.BindMounts,
+		Properties:         s.CommandLineJson.containerProperties(),
+		GraceTimeInSeconds: s.CommandLineJson.GraceTimeInSeconds,
+	})
+}
+
+func (s *State) attach(ctx context.Context) error {
+	return s.Container.Attach(ctx, s.CommandLineJson.Handle)
+}
 
-	return &input, err
+func (s *State) attached() bool {
+	return s.CommandLineJson.Handle != ""
 }
 
-func (s *State) Perform() {
-	s.Container.Create(s.CommandLineJson.BindMounts)
-	s.Container.SetDiskLimit(s.CommandLineJson.DiskLimitInBytes)
-	s.Container.SetMemoryLimit(s.CommandLineJson.MemoryLimitInBytes)
+func (s *State) steps() []step {
+	steps := []step{}
+	if !s.attached() && (len(s.CommandLineJson.EgressRules) > 0 || s.CommandLineJson.AllowBoundServices) {
+		steps = append(steps, step{"configure_egress", s.configureEgress})
+	}
+	steps = append(steps, []step{
+		{"set_disk_limit", s.setDiskLimit},
+		{"set_memory_limit", s.setMemoryLimit},
+	}...)
+	if s.CommandLineJson.FileDescriptorLimit != 0 {
+		steps = append(steps, step{"set_file_descriptor_limit", s.setFileDescriptorLimit})
+	}
+	if s.CommandLineJson.CpuLimitInShares != 0 {
+		steps = append(steps, step{"set_cpu_limit", s.setCpuLimit})
+	}
+	if s.CommandLineJson.BandwidthRateInBytesPerSecond != 0 {
+		steps = append(steps, step{"set_bandwidth_limit", s.setBandwidthLimit})
+	}
+	if !s.attached() {
+		steps = append(steps, s.createTimeSteps()...)
+	}
+	if s.CommandLineJson.Task != nil {
+		steps = append(steps, step{"run_task", s.runTask})
+		if len(s.CommandLineJson.Artifacts) > 0 {
+			steps = append(steps, step{"copy_out_artifacts", s.copyOutArtifacts})
+		}
+	}
+	return steps
+}
+
+func (s *State) createTimeSteps() []step {
+	steps := []step{{"configure_home_directory", s.Container.ConfigureHomeDirectory}}
+	if s.CommandLineJson.DropletPath != "" {
+		steps = append(steps, step{"install_droplet", s.installDroplet})
+	}
+	steps = append(steps,
+		step{"configure_application_ports", s.configureApplicationPorts},
+		step{"configure_console_ports", s.configureConsolePorts},
+	)
+	if s.CommandLineJson.Debug != "" {
+		steps = append(steps, step{"configure_debug_ports", s.configureDebugPorts})
+	}
+	return steps
+}
+
+func (s *State) installDroplet(ctx context.Context) error {
+	return s.Container.InstallDroplet(ctx, s.CommandLineJson.DropletPath)
+}
+
+func (s *State) copyOutArtifacts(ctx context.Context) error {
+	for _, artifact := range s.CommandLineJson.Artifacts {
+		err := s.Container.CopyOut(ctx, artifact.SrcPath, artifact.DstPath)
+		if err != nil {
+			return err
+		}
+		s.Result.Artifacts = append(s.Result.Artifacts, artifact.DstPath)
+	}
+	return nil
+}
+
+func (s *State) configureEgress(ctx context.Context) error {
+	rules := append([]*EgressRule{}, s.CommandLineJson.EgressRules...)
+	if s.CommandLineJson.AllowBoundServices {
+		serviceRules, err := ServiceEgressRules(s.CommandLineJson.Task.Environment.NatsData.Services, s.lookupIP)
+		if err != nil {
+			return err
+		}
+		rules = append(rules, serviceRules...)
+	}
+	return s.Container.AllowEgress(ctx, rules)
+}
+
+func (s *State) setDiskLimit(ctx context.Context) error {
+	applied, err := s.Container.SetDiskQuota(ctx, DiskQuota{
+		ByteSoft:  s.CommandLineJson.DiskSoftLimitInBytes,
+		ByteHard:  s.CommandLineJson.DiskLimitInBytes,
+		InodeSoft: s.CommandLineJson.DiskInodeSoftLimit,
+		InodeHard: s.CommandLineJson.DiskInodeLimit,
+	})
+	if err != nil {
+		return err
+	}
+	s.Result.Limits.DiskLimitInBytes = applied.ByteHard
+	s.Result.Limits.DiskSoftLimitInBytes = applied.ByteSoft
+	s.Result.Limits.DiskInodeLimit = applied.InodeHard
+	s.Result.Limits.DiskInodeSoftLimit = applied.InodeSoft
+	return nil
+}
+
+func (s *State) setMemoryLimit(ctx context.Context) error {
+	applied, err := s.Container.SetMemoryLimit(ctx, s.CommandLineJson.MemoryLimitInBytes)
+	if err == nil {
+		s.Result.Limits.MemoryLimitInBytes = applied
+	}
+	return err
+}
+
+func (s *State) setFileDescriptorLimit(ctx context.Context) error {
+	limit := s.CommandLineJson.FileDescriptorLimit
+	err := s.Container.SetFileDescriptorLimit(limit)
+	if err == nil {
+		s.Result.Limits.FileDescriptorLimit = limit
+	}
+	return err
+}
+
+func (s *State) setCpuLimit(ctx context.Context) error {
+	err := s.Container.SetCpuLimit(ctx, s.CommandLineJson.CpuLimitInShares)
+	if err == nil {
+		s.Result.Limits.CpuLimitInShares = s.CommandLineJson.CpuLimitInShares
+	}
+	return err
+}
+
+func (s *State) setBandwidthLimit(ctx context.Context) error {
+	rate := s.CommandLineJson.BandwidthRateInBytesPerSecond
+	burst := s.CommandLineJson.BandwidthBurstInBytes
+	err := s.Container.SetBandwidthLimit(ctx, rate, burst)
+	if err == nil {
+		s.Result.Limits.BandwidthRateInBytesPerSecond = rate
+		s.Result.Limits.BandwidthBurstInBytes = burst
+	}
+	return err
+}
+
+func (s *State) configureApplicationPorts(ctx context.Context) (err error) {
+	s.Result.Ports.Application, err = s.Container.ConfigureApplicationPorts(ctx)
+	return err
+}
+
+func (s *State) configureConsolePorts(ctx context.Context) (err error) {
+	s.Result.Ports.Console, err = s.Container.ConfigureConsolePorts(ctx)
+	return err
+}
+
+func (s *State) configureDebugPorts(ctx context.Context) (err error) {
+	s.Result.Ports.Debug, err = s.Container.ConfigureDebugPorts(ctx)
+	return err
+}
+
+func (s *State) runTask(ctx context.Context) error {
+	task := s.CommandLineJson.Task
+	environmentScript, err := task.EnvironmentScript(s.Result.Ports)
+	if err != nil {
+		return err
+	}
+
+	jobId, err := s.Container.SpawnTask(ctx, environmentScript, task.Command)
+	if err != nil {
+		return err
+	}
+	s.Result.Task = &TaskResult{JobId: jobId}
+
+	exitStatus, err := s.waitForTask(ctx, jobId, time.Duration(task.MaxDurationInSeconds)*time.Second)
+	if _, timedOut := err.(*TaskTimeoutError); timedOut {
+		return err
+	}
+
+	outOfMemory, oomErr := s.Container.OutOfMemory(ctx)
+	if oomErr == nil && outOfMemory {
+		s.Result.Task.FailureReason = FailureReasonOutOfMemory
+		s.Result.Task.MemoryLimitInBytes = s.Result.Limits.MemoryLimitInBytes
+		if err != nil {
+			return nil
+		}
+	}
+	if err != nil {
+		return err
+	}
+	s.Result.Task.ExitStatus = &exitStatus
+	return nil
+}
+
+func (s *State) waitForTask(ctx context.Context, jobId uint32, maxDuration time.Duration) (uint32, error) {
+	taskCtx := ctx
+	if maxDuration != 0 {
+		var cancel context.CancelFunc
+		taskCtx, cancel = context.WithTimeout(ctx, maxDuration)
+		defer cancel()
+	}
+
+	exitStatus, err := s.linkTask(taskCtx, jobId)
+	switch {
+	case err == context.DeadlineExceeded && ctx.Err() == nil:
+		s.Result.Task.FailureReason = FailureReasonTimedOut
+		s.killTask()
+		return 0, &TaskTimeoutError{MaxDuration: maxDuration}
+	}
+	return exitStatus, err
+}
+
+func (s *State) killTask() {
+	ctx, cancel := context.WithTimeout(context.Background(), s.CleanupTimeout)
+	defer cancel()
+	s.Container.Kill(ctx)
+}
+
+func (s *State) stopTask(linked <-chan error) (exited bool) {
+	if s.GracePeriod == 0 {
+		return false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), s.GracePeriod)
+	defer cancel()
+
+	s.Container.Stop(ctx)
+	select {
+	case <-linked:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (s *State) linkTask(ctx context.Context, jobId uint32) (uint32, error) {
+	linkCtx, cancelLink := context.WithCancel(context.Background())
+	defer cancelLink()
+
+	var exitStatus uint32
+	linked := make(chan error, 1)
+	go func() {
+		var err error
+		if s.TaskStdout != nil || s.TaskStderr != nil {
+			exitStatus, err = s.Container.StreamTask(linkCtx, jobId, s.TaskStdout, s.TaskStderr)
+		} else {
+			exitStatus, err = s.Container.LinkTask(linkCtx, jobId)
+		}
+		linked <- err
+	}()
+
+	select {
+	case err := <-linked:
+		return exitStatus, err
+	case <-ctx.Done():
+	}
+	if ctx.Err() == context.Canceled && s.stopTask(linked) {
+		return 0, ctx.Err()
+	}
+	cancelLink()
+	<-linked
+	return 0, ctx.Err()
+}
+
+func (s *State) Teardown(cancel context.CancelFunc, performed <-chan error) error {
+	cancel()
+
+	timeout := s.GracePeriod + s.CleanupTimeout
+	ctx, cancelCleanup := context.WithTimeout(context.Background(), timeout)
+	defer cancelCleanup()
+
+	select {
+	case <-performed:
+	case <-ctx.Done():
+		return fmt.Errorf("setup did not stop within %s", timeout)
+	}
+	if s.attached() {
+		return nil
+	}
+	return s.Container.Destroy(ctx)
 }
 
-func (c *CommandLineJson) IsValid() bool {
-	// TODO: logic
-	return false
+func (s *State) rollback(stepErr *StepError) error {
+	if s.attached() {
+		return stepErr
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), s.CleanupTimeout)
+	defer cancel()
+	stepErr.RollbackErr = s.Container.Destroy(ctx)
+	return stepErr
 }
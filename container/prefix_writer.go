@@ -0,0 +1,70 @@
+package container
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+type linePrefixWriter struct {
+	writer      io.Writer
+	prefix      []byte
+	atLineStart bool
+}
+
+func NewLinePrefixWriter(writer io.Writer, prefix string) io.Writer {
+	if prefix == "" {
+		return writer
+	}
+	return &linePrefixWriter{writer: writer, prefix: []byte(prefix), atLineStart: true}
+}
+
+func (w *linePrefixWriter) Write(data []byte) (int, error) {
+	var output bytes.Buffer
+	for _, b := range data {
+		if w.atLineStart {
+			output.Write(w.prefix)
+		}
+		output.WriteByte(b)
+		w.atLineStart = b == '\n'
+	}
+
+	_, err := w.writer.Write(output.Bytes())
+	if err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+type LineTrackingWriter struct {
+	writer      io.Writer
+	lock        sync.Mutex
+	atLineStart bool
+}
+
+func NewLineTrackingWriter(writer io.Writer) *LineTrackingWriter {
+	return &LineTrackingWriter{writer: writer, atLineStart: true}
+}
+
+func (w *LineTrackingWriter) Write(data []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	n, err := w.writer.Write(data)
+	if n > 0 {
+		w.atLineStart = data[n-1] == '\n'
+	}
+	return n, err
+}
+
+func (w *LineTrackingWriter) FinishLine() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.atLineStart {
+		return nil
+	}
+	_, err := w.writer.Write([]byte{'\n'})
+	if err == nil {
+		w.atLineStart = true
+	}
+	return err
+}
@@ -0,0 +1,47 @@
+package container
+
+import (
+	"bytes"
+	"io"
+	. "launchpad.net/gocheck"
+)
+
+type PrefixWriterSuite struct {
+}
+
+func init() {
+	Suite(&PrefixWriterSuite{})
+}
+
+func (s *PrefixWriterSuite) TestPrefixesEveryLine(c *C) {
+	output := &bytes.Buffer{}
+	writer := NewLinePrefixWriter(output, "[task] ")
+
+	io.WriteString(writer, "first li")
+	io.WriteString(writer, "ne\nsecond line\nthi")
+	n, err := io.WriteString(writer, "rd")
+
+	c.Assert(err, IsNil)
+	c.Assert(n, Equals, 2)
+	c.Assert(output.String(), Equals, "[task] first line\n[task] second line\n[task] third")
+}
+
+func (s *PrefixWriterSuite) TestEmptyPrefixReturnsTheWriter(c *C) {
+	output := &bytes.Buffer{}
+	c.Assert(NewLinePrefixWriter(output, ""), Equals, io.Writer(output))
+}
+
+func (s *PrefixWriterSuite) TestFinishLineEndsAnUnterminatedLine(c *C) {
+	output := &bytes.Buffer{}
+	tracker := NewLineTrackingWriter(output)
+	writer := NewLinePrefixWriter(tracker, "[task] ")
+
+	c.Assert(tracker.FinishLine(), IsNil)
+	io.WriteString(writer, "done\n")
+	c.Assert(tracker.FinishLine(), IsNil)
+	io.WriteString(writer, "done")
+	c.Assert(tracker.FinishLine(), IsNil)
+	c.Assert(tracker.FinishLine(), IsNil)
+
+	c.Assert(output.String(), Equals, "[task] done\n[task] done\n")
+}
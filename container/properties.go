@@ -0,0 +1,54 @@
+package container
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	warden "github.com/cloudfoundry/gordon"
+	"sort"
+)
+
+const (
+	PropertyAppName       = "app_name"
+	PropertyAppVersion    = "app_version"
+	PropertyInstanceGuid  = "instance_guid"
+	PropertyInstanceIndex = "instance_index"
+	PropertyTaskId        = "task_id"
+	PropertyCreateToken   = "create_token"
+)
+
+func FindContainers(ctx context.Context, client WardenClient, properties map[string]string) ([]string, error) {
+	var response *warden.ListResponse
+	err := withContext(ctx, func() (err error) {
+		response, err = client.ListByRequest(&warden.ListRequest{Properties: propertiesToRequest(properties)})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return response.GetHandles(), nil
+}
+
+func newCreateToken() (string, error) {
+	token := make([]byte, 16)
+	_, err := rand.Read(token)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(token), nil
+}
+
+func propertiesToRequest(properties map[string]string) []*warden.Property {
+	var keys []string
+	for key := range properties {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var requestProperties []*warden.Property
+	for _, key := range keys {
+		key, value := key, properties[key]
+		requestProperties = append(requestProperties, &warden.Property{Key: &key, Value: &value})
+	}
+	return requestProperties
+}
@@ -0,0 +1,50 @@
+package container
+
+import (
+	"context"
+	"errors"
+	warden "github.com/cloudfoundry/gordon"
+	. "launchpad.net/gocheck"
+)
+
+type PropertiesSuite struct {
+}
+
+func init() {
+	Suite(&PropertiesSuite{})
+}
+
+func (s *PropertiesSuite) TestFindContainers(c *C) {
+	var request *warden.ListRequest
+	fakeClient := MakeFakeWardenClient()
+	fakeClient.ListByRequestFunc = func(r *warden.ListRequest) (*warden.ListResponse, error) {
+		request = r
+		return &warden.ListResponse{Handles: []string{"handle-1", "handle-2"}}, nil
+	}
+
+	handles, err := FindContainers(context.Background(), fakeClient, map[string]string{
+		PropertyInstanceGuid: "BEEF",
+		PropertyAppName:      "simple-app",
+	})
+
+	c.Assert(err, IsNil)
+	c.Assert(handles, DeepEquals, []string{"handle-1", "handle-2"})
+	properties := request.GetProperties()
+	c.Assert(len(properties), Equals, 2)
+	c.Assert(properties[0].GetKey(), Equals, "app_name")
+	c.Assert(properties[0].GetValue(), Equals, "simple-app")
+	c.Assert(properties[1].GetKey(), Equals, "instance_guid")
+	c.Assert(properties[1].GetValue(), Equals, "BEEF")
+}
+
+func (s *PropertiesSuite) TestFindContainersError(c *C) {
+	fakeClient := MakeFakeWardenClient()
+	fakeClient.ListByRequestFunc = func(*warden.ListRequest) (*warden.ListResponse, error) {
+		return nil, errors.New("failed to list")
+	}
+
+	handles, err := FindContainers(context.Background(), fakeClient, map[string]string{PropertyTaskId: "task-1"})
+
+	c.Assert(handles, IsNil)
+	c.Assert(err.Error(), Equals, "failed to list")
+}
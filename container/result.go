@@ -0,0 +1,92 @@
+package container
+
+import (
+	"github.com/cloudfoundry/app_container_setup/parser"
+	"time"
+)
+
+type SetupResult struct {
+	Handle     string        `json:"handle"`
+	Limits     LimitsResult  `json:"limits"`
+	Ports      PortsResult   `json:"ports"`
+	Task       *TaskResult   `json:"task,omitempty"`
+	Artifacts  []string      `json:"artifacts,omitempty"`
+	Steps      []*StepResult `json:"steps"`
+	FailedStep string        `json:"failed_step,omitempty"`
+	Error      string        `json:"error,omitempty"`
+}
+
+type LimitsResult struct {
+	DiskLimitInBytes              uint64 `json:"disk_limit_in_bytes,omitempty"`
+	DiskSoftLimitInBytes          uint64 `json:"disk_soft_limit_in_bytes,omitempty"`
+	DiskInodeLimit                uint64 `json:"disk_inode_limit,omitempty"`
+	DiskInodeSoftLimit            uint64 `json:"disk_inode_soft_limit,omitempty"`
+	MemoryLimitInBytes            uint64 `json:"memory_limit_in_bytes,omitempty"`
+	FileDescriptorLimit           uint64 `json:"file_descriptor_limit,omitempty"`
+	CpuLimitInShares              uint64 `json:"cpu_limit_in_shares,omitempty"`
+	BandwidthRateInBytesPerSecond uint64 `json:"bandwidth_rate_in_bytes_per_second,omitempty"`
+	BandwidthBurstInBytes         uint64 `json:"bandwidth_burst_in_bytes,omitempty"`
+}
+
+type PortsResult struct {
+	Application *PortMapping `json:"application,omitempty"`
+	Console     *PortMapping `json:"console,omitempty"`
+	Debug       *PortMapping `json:"debug,omitempty"`
+}
+
+func (p PortsResult) ApplyTo(input *parser.InputJSON) {
+	if p.Application != nil {
+		input.InstanceContainerPort = int(p.Application.ContainerPort)
+	}
+	if p.Console != nil {
+		input.InstanceConsoleContainerPort = int(p.Console.ContainerPort)
+	}
+	if p.Debug != nil {
+		input.InstanceDebugContainerPort = int(p.Debug.ContainerPort)
+	}
+}
+
+const (
+	FailureReasonOutOfMemory = "out_of_memory"
+	FailureReasonTimedOut    = "timed_out"
+)
+
+type TaskResult struct {
+	JobId              uint32  `json:"job_id"`
+	ExitStatus         *uint32 `json:"exit_status,omitempty"`
+	FailureReason      string  `json:"failure_reason,omitempty"`
+	MemoryLimitInBytes uint64  `json:"memory_limit_in_bytes,omitempty"`
+}
+
+func (t *TaskResult) Failed() bool {
+	if t == nil {
+		return false
+	}
+	return t.FailureReason != "" || (t.ExitStatus != nil && *t.ExitStatus != 0)
+}
+
+type StepResult struct {
+	Name              string  `json:"name"`
+	DurationInSeconds float64 `json:"duration_in_seconds"`
+}
+
+func NewSetupResult() *SetupResult {
+	return &SetupResult{Steps: []*StepResult{}}
+}
+
+func (r *SetupResult) recordStep(name string, started time.Time) {
+	r.Steps = append(r.Steps, &StepResult{
+		Name:              name,
+		DurationInSeconds: time.Since(started).Seconds(),
+	})
+}
+
+func (r *SetupResult) SetError(err error) {
+	if err == nil {
+		return
+	}
+	r.Error = err.Error()
+	if stepErr, ok := err.(*StepError); ok {
+		r.FailedStep = stepErr.Step
+	}
+}
@@ -0,0 +1,64 @@
+package container
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/cloudfoundry/app_container_setup/parser"
+	. "launchpad.net/gocheck"
+)
+
+type ResultSuite struct {
+}
+
+func init() {
+	Suite(&ResultSuite{})
+}
+
+func (s *ResultSuite) TestSetErrorIgnoresNil(c *C) {
+	result := NewSetupResult()
+	result.SetError(nil)
+
+	c.Assert(result.Error, Equals, "")
+	c.Assert(result.FailedStep, Equals, "")
+}
+
+func (s *ResultSuite) TestSetErrorForInputError(c *C) {
+	result := NewSetupResult()
+	result.SetError(&InputError{Err: errors.New("unexpected end of JSON input")})
+
+	c.Assert(result.Error, Equals, "invalid input: unexpected end of JSON input")
+	c.Assert(result.FailedStep, Equals, "")
+}
+
+func (s *ResultSuite) TestSetErrorForStepError(c *C) {
+	result := NewSetupResult()
+	result.SetError(&StepError{Step: "create", Err: errors.New("no container for you")})
+
+	c.Assert(result.Error, Equals, "create failed: no container for you")
+	c.Assert(result.FailedStep, Equals, "create")
+}
+
+func (s *ResultSuite) TestMarshalling(c *C) {
+	result := NewSetupResult()
+	result.Handle = "wardenhandle"
+	result.Limits.DiskLimitInBytes = 100
+	result.Steps = append(result.Steps, &StepResult{Name: "create", DurationInSeconds: 0.5})
+
+	output, err := json.Marshal(result)
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Equals,
+		`{"handle":"wardenhandle","limits":{"disk_limit_in_bytes":100},"ports":{},"steps":[{"name":"create","duration_in_seconds":0.5}]}`)
+}
+
+func (s *ResultSuite) TestPortsApplyToParserInput(c *C) {
+	ports := PortsResult{
+		Application: &PortMapping{HostPort: 61001, ContainerPort: 8080},
+		Console:     &PortMapping{HostPort: 61002, ContainerPort: 8081},
+	}
+	input := &parser.InputJSON{InstanceDebugContainerPort: 1234}
+	ports.ApplyTo(input)
+
+	c.Assert(input.InstanceContainerPort, Equals, 8080)
+	c.Assert(input.InstanceConsoleContainerPort, Equals, 8081)
+	c.Assert(input.InstanceDebugContainerPort, Equals, 1234)
+}
@@ -0,0 +1,105 @@
+package container
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+var DefaultRetryPolicy = RetryPolicy{
+	Attempts:     5,
+	InitialDelay: 100 * time.Millisecond,
+	MaxDelay:     5 * time.Second,
+}
+
+type RetryPolicy struct {
+	Attempts     int
+	InitialDelay time.Duration
+	MaxDelay     time.Duration
+}
+
+type RetryJson struct {
+	Attempts                     int    `json:"attempts"`
+	InitialBackoffInMilliseconds uint64 `json:"initial_backoff_in_milliseconds"`
+	MaxBackoffInMilliseconds     uint64 `json:"max_backoff_in_milliseconds"`
+}
+
+func (r *RetryJson) Policy() RetryPolicy {
+	if r == nil {
+		return DefaultRetryPolicy
+	}
+
+	policy := RetryPolicy{
+		Attempts:     r.Attempts,
+		InitialDelay: time.Duration(r.InitialBackoffInMilliseconds) * time.Millisecond,
+		MaxDelay:     time.Duration(r.MaxBackoffInMilliseconds) * time.Millisecond,
+	}
+	if policy.Attempts == 0 {
+		policy.Attempts = DefaultRetryPolicy.Attempts
+	}
+	if policy.InitialDelay == 0 {
+		policy.InitialDelay = DefaultRetryPolicy.InitialDelay
+	}
+	if policy.MaxDelay == 0 {
+		policy.MaxDelay = DefaultRetryPolicy.MaxDelay
+	}
+	return policy
+}
+
+func (p RetryPolicy) backoff(attempt int, jitter func(int64) int64) time.Duration {
+	delay := p.InitialDelay
+	for i := 0; i < attempt && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	half := int64(delay / 2)
+	return time.Duration(half + jitter(half+1))
+}
+
+func retry(ctx context.Context, policy RetryPolicy, request func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := request()
+		if err == nil || !isTransient(err) || attempt+1 >= policy.Attempts {
+			return err
+		}
+
+		select {
+		case <-time.After(policy.backoff(attempt, rand.Int63n)):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func isTransient(err error) bool {
+	if err == context.Canceled || err == context.DeadlineExceeded {
+		return false
+	}
+	_, wardenErr := err.(*WardenError)
+	return !wardenErr
+}
+
+func (c *Container) SetRetryPolicy(policy RetryPolicy) {
+	c.retryPolicy = policy
+}
+
+func (c *Container) Connect(ctx context.Context) error {
+	return retry(ctx, c.retryPolicy, func() error {
+		return withContext(ctx, c.client.Connect)
+	})
+}
+
+func (c *Container) retrying(ctx context.Context, request func() error) error {
+	reconnect := false
+	return retry(ctx, c.retryPolicy, func() error {
+		if reconnect {
+			if err := withContext(ctx, c.client.Connect); err != nil {
+				return err
+			}
+		}
+		reconnect = true
+		return withContext(ctx, request)
+	})
+}
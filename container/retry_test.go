@@ -0,0 +1,201 @@
+package container
+
+import (
+	"context"
+	"errors"
+	warden "github.com/cloudfoundry/gordon"
+	. "launchpad.net/gocheck"
+	"time"
+)
+
+type RetrySuite struct {
+	connects   int
+	fakeClient *fakeWardenClient
+	container  *Container
+}
+
+func init() {
+	Suite(&RetrySuite{})
+}
+
+func (s *RetrySuite) SetUpTest(c *C) {
+	s.connects = 0
+	s.fakeClient = MakeFakeWardenClient()
+	s.fakeClient.ConnectFunc = func() error {
+		s.connects++
+		return nil
+	}
+	s.container = NewContainer(s.fakeClient)
+	s.container.handle = "the_warden_handle"
+	s.container.SetRetryPolicy(RetryPolicy{Attempts: 3, InitialDelay: time.Millisecond, MaxDelay: 2 * time.Millisecond})
+}
+
+func (s *RetrySuite) TestPolicyFromJson(c *C) {
+	var missing *RetryJson
+	c.Assert(missing.Policy(), Equals, DefaultRetryPolicy)
+	c.Assert((&RetryJson{Attempts: 1}).Policy(), Equals, RetryPolicy{
+		Attempts:     1,
+		InitialDelay: 100 * time.Millisecond,
+		MaxDelay:     5 * time.Second,
+	})
+	c.Assert((&RetryJson{Attempts: 3, InitialBackoffInMilliseconds: 10, MaxBackoffInMilliseconds: 50}).Policy(), Equals, RetryPolicy{
+		Attempts:     3,
+		InitialDelay: 10 * time.Millisecond,
+		MaxDelay:     50 * time.Millisecond,
+	})
+	c.Assert((&RetryJson{MaxBackoffInMilliseconds: 1000}).Policy(), Equals, RetryPolicy{
+		Attempts:     5,
+		InitialDelay: 100 * time.Millisecond,
+		MaxDelay:     time.Second,
+	})
+}
+
+func (s *RetrySuite) TestBackoffDoublesUpToTheMaximumWithJitter(c *C) {
+	policy := RetryPolicy{InitialDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	noJitter := func(int64) int64 { return 0 }
+	fullJitter := func(n int64) int64 { return n - 1 }
+
+	c.Assert(policy.backoff(0, noJitter), Equals, 50*time.Millisecond)
+	c.Assert(policy.backoff(1, noJitter), Equals, 100*time.Millisecond)
+	c.Assert(policy.backoff(2, noJitter), Equals, 200*time.Millisecond)
+	c.Assert(policy.backoff(10, noJitter), Equals, 500*time.Millisecond)
+	c.Assert(policy.backoff(2, fullJitter), Equals, 400*time.Millisecond)
+	c.Assert(policy.backoff(100, fullJitter), Equals, time.Second)
+}
+
+func (s *RetrySuite) TestConnectRetriesUntilWardenIsBack(c *C) {
+	s.fakeClient.ConnectFunc = func() error {
+		s.connects++
+		if s.connects < 3 {
+			return errors.New("connection refused")
+		}
+		return nil
+	}
+
+	err := s.container.Connect(context.Background())
+
+	c.Assert(err, IsNil)
+	c.Assert(s.connects, Equals, 3)
+}
+
+func (s *RetrySuite) TestConnectGivesUpAfterTheLastAttempt(c *C) {
+	s.fakeClient.ConnectFunc = func() error {
+		s.connects++
+		return errors.New("connection refused")
+	}
+
+	err := s.container.Connect(context.Background())
+
+	c.Assert(err.Error(), Equals, "connection refused")
+	c.Assert(s.connects, Equals, 3)
+}
+
+func (s *RetrySuite) TestIdempotentCallsReconnectAndRetry(c *C) {
+	calls := 0
+	s.fakeClient.LimitMemoryFunc = func(string, uint64) (*warden.LimitMemoryResponse, error) {
+		calls++
+		if calls < 3 {
+			return nil, errors.New("broken pipe")
+		}
+		return &warden.LimitMemoryResponse{}, nil
+	}
+
+	_, err := s.container.SetMemoryLimit(context.Background(), 1024)
+
+	c.Assert(err, IsNil)
+	c.Assert(calls, Equals, 3)
+	c.Assert(s.connects, Equals, 2)
+}
+
+func (s *RetrySuite) TestDestroyReconnectsAfterTheConnectionDropped(c *C) {
+	calls := 0
+	s.fakeClient.DestroyFunc = func(string) (*warden.DestroyResponse, error) {
+		calls++
+		if calls < 2 {
+			return nil, errors.New("broken pipe")
+		}
+		return &warden.DestroyResponse{}, nil
+	}
+
+	err := s.container.Destroy(context.Background())
+
+	c.Assert(err, IsNil)
+	c.Assert(calls, Equals, 2)
+	c.Assert(s.connects, Equals, 1)
+	c.Assert(s.container.Handle(), Equals, "")
+}
+
+func (s *RetrySuite) TestWardenErrorsAreNotRetried(c *C) {
+	calls := 0
+	s.fakeClient.InfoFunc = func(string) (*warden.InfoResponse, error) {
+		calls++
+		return nil, &WardenError{Message: "unknown handle"}
+	}
+
+	_, err := s.container.OutOfMemory(context.Background())
+
+	c.Assert(err.Error(), Equals, "unknown handle")
+	c.Assert(calls, Equals, 1)
+	c.Assert(s.connects, Equals, 0)
+}
+
+func (s *RetrySuite) TestNonIdempotentCallsAreNotRetried(c *C) {
+	calls := 0
+	s.fakeClient.NetInFunc = func(string) (*warden.NetInResponse, error) {
+		calls++
+		return nil, errors.New("broken pipe")
+	}
+
+	_, err := s.container.ConfigureApplicationPorts(context.Background())
+
+	c.Assert(err.Error(), Equals, "broken pipe")
+	c.Assert(calls, Equals, 1)
+}
+
+func (s *RetrySuite) TestCreateAdoptsAContainerCreatedBeforeTheConnectionDropped(c *C) {
+	var createRequests []*warden.CreateRequest
+	var listRequest *warden.ListRequest
+	s.fakeClient.CreateByRequestFunc = func(r *warden.CreateRequest) (*warden.CreateResponse, error) {
+		createRequests = append(createRequests, r)
+		return nil, errors.New("broken pipe")
+	}
+	s.fakeClient.ListByRequestFunc = func(r *warden.ListRequest) (*warden.ListResponse, error) {
+		listRequest = r
+		return &warden.ListResponse{Handles: []string{"created-handle"}}, nil
+	}
+	s.container.handle = ""
+
+	err := s.container.Create(context.Background(), &ContainerSpec{Properties: map[string]string{"app_name": "simple-app"}})
+
+	c.Assert(err, IsNil)
+	c.Assert(s.container.Handle(), Equals, "created-handle")
+	c.Assert(len(createRequests), Equals, 1)
+	c.Assert(s.connects, Equals, 1)
+
+	properties := createRequests[0].GetProperties()
+	c.Assert(len(properties), Equals, 2)
+	c.Assert(properties[1].GetKey(), Equals, "create_token")
+	c.Assert(len(properties[1].GetValue()), Equals, 32)
+	c.Assert(len(listRequest.GetProperties()), Equals, 1)
+	c.Assert(listRequest.GetProperties()[0].GetKey(), Equals, "create_token")
+	c.Assert(listRequest.GetProperties()[0].GetValue(), Equals, properties[1].GetValue())
+}
+
+func (s *RetrySuite) TestCreateRetriesWhenNothingWasCreated(c *C) {
+	creates := 0
+	s.fakeClient.CreateByRequestFunc = func(*warden.CreateRequest) (*warden.CreateResponse, error) {
+		creates++
+		if creates == 1 {
+			return nil, errors.New("broken pipe")
+		}
+		handle := "new-handle"
+		return &warden.CreateResponse{Handle: &handle}, nil
+	}
+	s.container.handle = ""
+
+	err := s.container.Create(context.Background(), &ContainerSpec{})
+
+	c.Assert(err, IsNil)
+	c.Assert(s.container.Handle(), Equals, "new-handle")
+	c.Assert(creates, Equals, 2)
+}
@@ -0,0 +1,128 @@
+package container
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/cloudfoundry/app_container_setup/parser"
+	warden "github.com/cloudfoundry/gordon"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+const environmentScriptPath = homeDirectoryPath + "/environment.sh"
+
+type TaskJson struct {
+	Id                   string            `json:"id"`
+	Command              string            `json:"command"`
+	MaxDurationInSeconds uint64            `json:"max_duration_in_seconds"`
+	Environment          *parser.InputJSON `json:"environment"`
+}
+
+func (t *TaskJson) EnvironmentScript(ports PortsResult) (string, error) {
+	environment := parser.InputJSON{}
+	if t.Environment != nil {
+		environment = *t.Environment
+	}
+	ports.ApplyTo(&environment)
+
+	environmentJson, err := json.Marshal(environment)
+	if err != nil {
+		return "", err
+	}
+	return parser.NewParser().GenerateEnvironmentScriptFromJSON(string(environmentJson))
+}
+
+func (c *Container) SpawnTask(ctx context.Context, environmentScript string, command string) (uint32, error) {
+	err := c.uploadEnvironmentScript(ctx, environmentScript)
+	if err != nil {
+		return 0, err
+	}
+
+	script := fmt.Sprintf("cd %s && source %s && %s", homeDirectoryPath, environmentScriptPath, command)
+	var response *warden.SpawnResponse
+	err = withContext(ctx, func() (err error) {
+		response, err = c.client.SpawnByRequest(&warden.SpawnRequest{
+			Handle:  &c.handle,
+			Script:  &script,
+			Rlimits: c.rlimits,
+		})
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return response.GetJobId(), nil
+}
+
+func (c *Container) LinkTask(ctx context.Context, jobId uint32) (uint32, error) {
+	var response *warden.LinkResponse
+	err := withContext(ctx, func() (err error) {
+		response, err = c.client.Link(c.handle, jobId)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return response.GetExitStatus(), nil
+}
+
+func (c *Container) StreamTask(ctx context.Context, jobId uint32, stdout, stderr io.Writer) (uint32, error) {
+	var responses chan *warden.StreamResponse
+	err := withContext(ctx, func() (err error) {
+		responses, err = c.client.Stream(c.handle, jobId)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for {
+		var response *warden.StreamResponse
+		select {
+		case response = <-responses:
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+		if response == nil {
+			break
+		}
+
+		if response.ExitStatus != nil {
+			return response.GetExitStatus(), nil
+		}
+
+		var output io.Writer
+		switch response.GetName() {
+		case "stdout":
+			output = stdout
+		case "stderr":
+			output = stderr
+		}
+		if output != nil {
+			_, err = io.WriteString(output, response.GetData())
+			if err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	return c.LinkTask(ctx, jobId)
+}
+
+func (c *Container) uploadEnvironmentScript(ctx context.Context, environmentScript string) error {
+	file, err := ioutil.TempFile("", "environment")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	_, err = file.WriteString(environmentScript)
+	file.Close()
+	if err != nil {
+		return err
+	}
+
+	return c.copyIn(ctx, file.Name(), environmentScriptPath)
+}
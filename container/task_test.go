@@ -0,0 +1,201 @@
+package container
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/cloudfoundry/app_container_setup/parser"
+	warden "github.com/cloudfoundry/gordon"
+	"io/ioutil"
+	. "launchpad.net/gocheck"
+	"time"
+)
+
+type TaskSuite struct {
+}
+
+func init() {
+	Suite(&TaskSuite{})
+}
+
+func (s *TaskSuite) TestEnvironmentScriptUsesMappedPorts(c *C) {
+	task := &TaskJson{Environment: &parser.InputJSON{InstanceGuid: "BEEF", InstanceContainerPort: 1}}
+	ports := PortsResult{
+		Application: &PortMapping{HostPort: 61001, ContainerPort: 8080},
+		Console:     &PortMapping{HostPort: 61002, ContainerPort: 8081},
+	}
+
+	script, err := task.EnvironmentScript(ports)
+
+	c.Assert(err, IsNil)
+	c.Assert(script, Matches, `(?s).*export PORT="8080"\n.*`)
+	c.Assert(script, Matches, `(?s).*export VCAP_CONSOLE_PORT="8081"\n.*`)
+	c.Assert(script, Matches, `(?s).*instance_id\\":\\"BEEF.*`)
+	c.Assert(task.Environment.InstanceContainerPort, Equals, 1)
+}
+
+func (s *TaskSuite) TestSpawnTask(c *C) {
+	var copiedScript, copiedTo string
+	var request *warden.SpawnRequest
+	fakeClient := MakeFakeWardenClient()
+	fakeClient.CopyInFunc = func(handle, src, dst string) (*warden.CopyInResponse, error) {
+		c.Assert(handle, Equals, "the_warden_handle")
+		contents, err := ioutil.ReadFile(src)
+		c.Assert(err, IsNil)
+		copiedScript = string(contents)
+		copiedTo = dst
+		return nil, nil
+	}
+	fakeClient.SpawnByRequestFunc = func(r *warden.SpawnRequest) (*warden.SpawnResponse, error) {
+		request = r
+		jobId := uint32(7)
+		return &warden.SpawnResponse{JobId: &jobId}, nil
+	}
+
+	container := NewContainer(fakeClient)
+	container.handle = "the_warden_handle"
+
+	jobId, err := container.SpawnTask(context.Background(), "export FOO=\"bar\"\n", "rake db:migrate")
+
+	c.Assert(err, IsNil)
+	c.Assert(jobId, Equals, uint32(7))
+	c.Assert(copiedScript, Equals, "export FOO=\"bar\"\n")
+	c.Assert(copiedTo, Equals, "/home/vcap/environment.sh")
+	c.Assert(request.GetHandle(), Equals, "the_warden_handle")
+	c.Assert(request.GetScript(), Equals, "cd /home/vcap && source /home/vcap/environment.sh && rake db:migrate")
+}
+
+func (s *TaskSuite) TestSpawnTaskUploadError(c *C) {
+	spawned := false
+	fakeClient := MakeFakeWardenClient()
+	fakeClient.CopyInFunc = func(string, string, string) (*warden.CopyInResponse, error) {
+		return nil, errors.New("failed to copy in")
+	}
+	fakeClient.SpawnByRequestFunc = func(*warden.SpawnRequest) (*warden.SpawnResponse, error) {
+		spawned = true
+		return &warden.SpawnResponse{}, nil
+	}
+
+	container := NewContainer(fakeClient)
+	_, err := container.SpawnTask(context.Background(), "", "true")
+
+	c.Assert(err.Error(), Equals, "failed to copy in")
+	c.Assert(spawned, Equals, false)
+}
+
+func (s *TaskSuite) TestLinkTask(c *C) {
+	var handle string
+	var jobId uint32
+	fakeClient := MakeFakeWardenClient()
+	fakeClient.LinkFunc = func(h string, j uint32) (*warden.LinkResponse, error) {
+		handle, jobId = h, j
+		exitStatus := uint32(2)
+		return &warden.LinkResponse{ExitStatus: &exitStatus}, nil
+	}
+
+	container := NewContainer(fakeClient)
+	container.handle = "the_warden_handle"
+
+	exitStatus, err := container.LinkTask(context.Background(), 7)
+
+	c.Assert(err, IsNil)
+	c.Assert(exitStatus, Equals, uint32(2))
+	c.Assert(handle, Equals, "the_warden_handle")
+	c.Assert(jobId, Equals, uint32(7))
+}
+
+func (s *TaskSuite) TestLinkTaskError(c *C) {
+	fakeClient := MakeFakeWardenClient()
+	fakeClient.LinkFunc = func(string, uint32) (*warden.LinkResponse, error) {
+		return nil, errors.New("failed to link")
+	}
+
+	container := NewContainer(fakeClient)
+	_, err := container.LinkTask(context.Background(), 7)
+
+	c.Assert(err.Error(), Equals, "failed to link")
+}
+
+func streamResponse(name, data string) *warden.StreamResponse {
+	return &warden.StreamResponse{Name: &name, Data: &data}
+}
+
+func (s *TaskSuite) TestStreamTask(c *C) {
+	fakeClient := MakeFakeWardenClient()
+	fakeClient.StreamFunc = func(h string, jobId uint32) (chan *warden.StreamResponse, error) {
+		c.Assert(h, Equals, "the_warden_handle")
+		c.Assert(jobId, Equals, uint32(7))
+
+		responses := make(chan *warden.StreamResponse, 4)
+		exitStatus := uint32(1)
+		responses <- streamResponse("stdout", "migrating\n")
+		responses <- streamResponse("stderr", "oops\n")
+		responses <- streamResponse("stdout", "done\n")
+		responses <- &warden.StreamResponse{ExitStatus: &exitStatus}
+		close(responses)
+		return responses, nil
+	}
+
+	container := NewContainer(fakeClient)
+	container.handle = "the_warden_handle"
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	exitStatus, err := container.StreamTask(context.Background(), 7, stdout, stderr)
+
+	c.Assert(err, IsNil)
+	c.Assert(exitStatus, Equals, uint32(1))
+	c.Assert(stdout.String(), Equals, "migrating\ndone\n")
+	c.Assert(stderr.String(), Equals, "oops\n")
+}
+
+func (s *TaskSuite) TestStreamTaskFallsBackToLinkWhenStreamEndsEarly(c *C) {
+	fakeClient := MakeFakeWardenClient()
+	fakeClient.StreamFunc = func(string, uint32) (chan *warden.StreamResponse, error) {
+		responses := make(chan *warden.StreamResponse, 1)
+		responses <- streamResponse("stdout", "partial")
+		close(responses)
+		return responses, nil
+	}
+	fakeClient.LinkFunc = func(string, uint32) (*warden.LinkResponse, error) {
+		exitStatus := uint32(0)
+		return &warden.LinkResponse{ExitStatus: &exitStatus}, nil
+	}
+
+	container := NewContainer(fakeClient)
+	stdout := &bytes.Buffer{}
+	exitStatus, err := container.StreamTask(context.Background(), 7, stdout, nil)
+
+	c.Assert(err, IsNil)
+	c.Assert(exitStatus, Equals, uint32(0))
+	c.Assert(stdout.String(), Equals, "partial")
+}
+
+func (s *TaskSuite) TestStreamTaskStopsWhenCancelled(c *C) {
+	fakeClient := MakeFakeWardenClient()
+	fakeClient.StreamFunc = func(string, uint32) (chan *warden.StreamResponse, error) {
+		responses := make(chan *warden.StreamResponse, 1)
+		responses <- streamResponse("stdout", "partial")
+		return responses, nil
+	}
+
+	container := NewContainer(fakeClient)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	stdout := &bytes.Buffer{}
+	_, err := container.StreamTask(ctx, 7, stdout, nil)
+
+	c.Assert(err, Equals, context.DeadlineExceeded)
+	c.Assert(stdout.String(), Equals, "partial")
+}
+
+func (s *TaskSuite) TestStreamTaskError(c *C) {
+	fakeClient := MakeFakeWardenClient()
+	fakeClient.StreamFunc = func(string, uint32) (chan *warden.StreamResponse, error) {
+		return nil, errors.New("failed to stream")
+	}
+
+	container := NewContainer(fakeClient)
+	_, err := container.StreamTask(context.Background(), 7, nil, nil)
+
+	c.Assert(err.Error(), Equals, "failed to stream")
+}
@@ -0,0 +1,180 @@
+package container
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return strings.Join(e.Problems, "; ")
+}
+
+func joinValidationErrors(errs ...error) error {
+	var problems []string
+	for _, err := range errs {
+		switch err := err.(type) {
+		case nil:
+		case *ValidationError:
+			problems = append(problems, err.Problems...)
+		default:
+			return err
+		}
+	}
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+func (c *CommandLineJson) Validate() error {
+	var problems []string
+
+	if _, ok := backends[c.backend()]; !ok {
+		problems = append(problems, fmt.Sprintf("backend %q is not one of %s", c.Backend, strings.Join(Backends(), ", ")))
+	} else if c.backend() == DefaultBackend && c.WardenSocketPath == "" {
+		problems = append(problems, "warden_socket_path is required")
+	}
+	if c.DiskLimitInBytes == 0 {
+		problems = append(problems, "disk_limit_in_bytes must be greater than zero")
+	}
+	if c.DiskSoftLimitInBytes > c.DiskLimitInBytes {
+		problems = append(problems, "disk_soft_limit_in_bytes must not exceed disk_limit_in_bytes")
+	}
+	if c.DiskInodeSoftLimit != 0 && c.DiskInodeLimit != 0 && c.DiskInodeSoftLimit > c.DiskInodeLimit {
+		problems = append(problems, "disk_inode_soft_limit must not exceed disk_inode_limit")
+	}
+	if c.MemoryLimitInBytes == 0 {
+		problems = append(problems, "memory_limit_in_bytes must be greater than zero")
+	}
+
+	if c.Task != nil && c.Task.Command == "" {
+		problems = append(problems, "task.command is required")
+	}
+	if c.BandwidthRateInBytesPerSecond != 0 && c.BandwidthBurstInBytes == 0 {
+		problems = append(problems, "bandwidth_burst_in_bytes is required when bandwidth_rate_in_bytes_per_second is set")
+	}
+	if c.BandwidthBurstInBytes != 0 && c.BandwidthRateInBytesPerSecond == 0 {
+		problems = append(problems, "bandwidth_rate_in_bytes_per_second is required when bandwidth_burst_in_bytes is set")
+	}
+
+	if c.Handle != "" && len(c.BindMounts) > 0 {
+		problems = append(problems, "bind_mounts cannot be applied to an existing container handle")
+	}
+	if c.Handle != "" && c.GraceTimeInSeconds != 0 {
+		problems = append(problems, "grace_time_in_seconds cannot be applied to an existing container handle")
+	}
+	if c.Handle != "" && (len(c.EgressRules) > 0 || c.AllowBoundServices) {
+		problems = append(problems, "egress_rules and allow_bound_services cannot be applied to an existing container handle")
+	}
+	if c.Handle != "" && c.DropletPath != "" {
+		problems = append(problems, "droplet_path cannot be applied to an existing container handle")
+	}
+	if c.Handle != "" && c.Debug != "" {
+		problems = append(problems, "debug ports cannot be mapped on an existing container handle")
+	}
+
+	destinations := map[string]bool{}
+	for i, bindMount := range c.BindMounts {
+		problems = append(problems, bindMount.validate(i)...)
+
+		if destinations[bindMount.DstPath] {
+			problems = append(problems, fmt.Sprintf("bind_mounts[%d].dst_path %s is already bound", i, bindMount.DstPath))
+		}
+		destinations[bindMount.DstPath] = true
+	}
+
+	if c.DropletPath != "" {
+		if _, err := os.Stat(c.DropletPath); err != nil {
+			problems = append(problems, fmt.Sprintf("droplet_path %s does not exist", c.DropletPath))
+		}
+	}
+	if len(c.Artifacts) > 0 && c.Task == nil {
+		problems = append(problems, "artifacts require a task")
+	}
+	for i, artifact := range c.Artifacts {
+		problems = append(problems, artifact.validate(i)...)
+	}
+
+	if c.WardenRetries != nil {
+		if c.WardenRetries.Attempts < 0 {
+			problems = append(problems, "warden_retries.attempts must not be negative")
+		}
+		if c.WardenRetries.MaxBackoffInMilliseconds != 0 && c.WardenRetries.InitialBackoffInMilliseconds > c.WardenRetries.MaxBackoffInMilliseconds {
+			problems = append(problems, "warden_retries.initial_backoff_in_milliseconds must not exceed warden_retries.max_backoff_in_milliseconds")
+		}
+	}
+
+	if c.AllowBoundServices && (c.Task == nil || c.Task.Environment == nil) {
+		problems = append(problems, "allow_bound_services requires task.environment")
+	}
+	for i, rule := range c.EgressRules {
+		if _, err := rule.toRequest(""); err != nil {
+			problems = append(problems, fmt.Sprintf("egress_rules[%d]: %s", i, err))
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+func (b *BindMount) validate(index int) []string {
+	var problems []string
+	field := fmt.Sprintf("bind_mounts[%d]", index)
+
+	if !filepath.IsAbs(b.SrcPath) {
+		problems = append(problems, fmt.Sprintf("%s.src_path %q must be absolute", field, b.SrcPath))
+	} else if strings.ToLower(b.Origin) != "container" {
+		if _, err := os.Stat(b.SrcPath); err != nil {
+			problems = append(problems, fmt.Sprintf("%s.src_path %s does not exist", field, b.SrcPath))
+		}
+	}
+	if !filepath.IsAbs(b.DstPath) {
+		problems = append(problems, fmt.Sprintf("%s.dst_path %q must be absolute", field, b.DstPath))
+	}
+	if _, err := b.wardenMode(); err != nil {
+		problems = append(problems, fmt.Sprintf("%s: %s", field, err))
+	}
+	if _, err := b.wardenOrigin(); err != nil {
+		problems = append(problems, fmt.Sprintf("%s: %s", field, err))
+	}
+	return problems
+}
+
+func (a *Artifact) validate(index int) []string {
+	var problems []string
+	field := fmt.Sprintf("artifacts[%d]", index)
+
+	if !filepath.IsAbs(a.SrcPath) {
+		problems = append(problems, fmt.Sprintf("%s.src_path %q must be absolute", field, a.SrcPath))
+	}
+	if !filepath.IsAbs(a.DstPath) {
+		problems = append(problems, fmt.Sprintf("%s.dst_path %q must be absolute", field, a.DstPath))
+	}
+	return problems
+}
+
+func (c *CommandLineJson) ValidateStatus() error {
+	var problems []string
+
+	if _, ok := backends[c.backend()]; !ok {
+		problems = append(problems, fmt.Sprintf("backend %q is not one of %s", c.Backend, strings.Join(Backends(), ", ")))
+	} else if c.backend() == DefaultBackend && c.WardenSocketPath == "" {
+		problems = append(problems, "warden_socket_path is required")
+	}
+	if c.Handle == "" {
+		problems = append(problems, "handle is required")
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
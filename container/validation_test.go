@@ -0,0 +1,211 @@
+package container
+
+import (
+	"github.com/cloudfoundry/app_container_setup/parser"
+	"io/ioutil"
+	. "launchpad.net/gocheck"
+	"os"
+)
+
+type ValidationSuite struct {
+	srcPath string
+}
+
+func init() {
+	Suite(&ValidationSuite{})
+}
+
+func (s *ValidationSuite) SetUpTest(c *C) {
+	var err error
+	s.srcPath, err = ioutil.TempDir("", "app_container_setup")
+	c.Assert(err, IsNil)
+}
+
+func (s *ValidationSuite) TearDownTest(c *C) {
+	os.RemoveAll(s.srcPath)
+}
+
+func (s *ValidationSuite) validInput() *CommandLineJson {
+	return &CommandLineJson{
+		DiskLimitInBytes:   100,
+		MemoryLimitInBytes: 200,
+		WardenSocketPath:   "/tmp/warden.sock",
+		BindMounts: []*BindMount{
+			{SrcPath: s.srcPath, DstPath: "/path/dst", Mode: "ro"},
+		},
+	}
+}
+
+func (s *ValidationSuite) TestValidInput(c *C) {
+	c.Assert(s.validInput().Validate(), IsNil)
+}
+
+func (s *ValidationSuite) TestMissingValues(c *C) {
+	err := (&CommandLineJson{}).Validate()
+
+	validationErr, ok := err.(*ValidationError)
+	c.Assert(ok, Equals, true)
+	c.Assert(validationErr.Problems, DeepEquals, []string{
+		"warden_socket_path is required",
+		"disk_limit_in_bytes must be greater than zero",
+		"memory_limit_in_bytes must be greater than zero",
+	})
+	c.Assert(err.Error(), Equals, "warden_socket_path is required; "+
+		"disk_limit_in_bytes must be greater than zero; "+
+		"memory_limit_in_bytes must be greater than zero")
+}
+
+func (s *ValidationSuite) TestBackend(c *C) {
+	input := s.validInput()
+	input.Backend = "docker"
+
+	c.Assert(input.Validate(), DeepEquals, &ValidationError{Problems: []string{
+		`backend "docker" is not one of local, warden`,
+	}})
+
+	input.Backend = "local"
+	input.WardenSocketPath = ""
+	c.Assert(input.Validate(), IsNil)
+}
+
+func (s *ValidationSuite) TestInvalidBindMounts(c *C) {
+	input := s.validInput()
+	input.BindMounts = append(input.BindMounts,
+		&BindMount{SrcPath: "relative/src", DstPath: "relative/dst"},
+		&BindMount{SrcPath: "/does/not/exist", DstPath: "/path/other", Mode: "rwx"},
+		&BindMount{SrcPath: s.srcPath, DstPath: "/path/dst", Origin: "guest"},
+	)
+
+	err := input.Validate()
+
+	c.Assert(err.(*ValidationError).Problems, DeepEquals, []string{
+		`bind_mounts[1].src_path "relative/src" must be absolute`,
+		`bind_mounts[1].dst_path "relative/dst" must be absolute`,
+		"bind_mounts[2].src_path /does/not/exist does not exist",
+		`bind_mounts[2]: invalid mode "rwx" for bind mount /path/other, expected RO or RW`,
+		`bind_mounts[3]: invalid origin "guest" for bind mount /path/dst, expected host or container`,
+		"bind_mounts[3].dst_path /path/dst is already bound",
+	})
+}
+
+func (s *ValidationSuite) TestContainerOriginSourcesNeedNotExistOnHost(c *C) {
+	input := s.validInput()
+	input.BindMounts = append(input.BindMounts,
+		&BindMount{SrcPath: "/inside/container", DstPath: "/path/other", Origin: "container"})
+
+	c.Assert(input.Validate(), IsNil)
+}
+
+func (s *ValidationSuite) TestCreateOptionsCannotBeAppliedWhenAttaching(c *C) {
+	input := s.validInput()
+	input.Handle = "existing-handle"
+	input.GraceTimeInSeconds = 300
+	input.EgressRules = []*EgressRule{{Network: "10.0.0.0/8"}}
+	input.DropletPath = s.srcPath
+	input.Debug = "run"
+
+	c.Assert(input.Validate(), DeepEquals, &ValidationError{Problems: []string{
+		"bind_mounts cannot be applied to an existing container handle",
+		"grace_time_in_seconds cannot be applied to an existing container handle",
+		"egress_rules and allow_bound_services cannot be applied to an existing container handle",
+		"droplet_path cannot be applied to an existing container handle",
+		"debug ports cannot be mapped on an existing container handle",
+	}})
+
+	input.BindMounts = nil
+	input.GraceTimeInSeconds = 0
+	input.EgressRules = nil
+	input.DropletPath = ""
+	input.Debug = ""
+	c.Assert(input.Validate(), IsNil)
+}
+
+func (s *ValidationSuite) TestDropletAndArtifacts(c *C) {
+	input := s.validInput()
+	input.DropletPath = "/does/not/exist.tgz"
+	input.Artifacts = []*Artifact{{SrcPath: "relative/report.csv", DstPath: "/tmp/report.csv"}}
+
+	c.Assert(input.Validate(), DeepEquals, &ValidationError{Problems: []string{
+		"droplet_path /does/not/exist.tgz does not exist",
+		"artifacts require a task",
+		`artifacts[0].src_path "relative/report.csv" must be absolute`,
+	}})
+
+	input.DropletPath = s.srcPath
+	input.Task = &TaskJson{Command: "true"}
+	input.Artifacts[0].SrcPath = "/home/vcap/app/report.csv"
+	c.Assert(input.Validate(), IsNil)
+}
+
+func (s *ValidationSuite) TestWardenRetries(c *C) {
+	input := s.validInput()
+	input.WardenRetries = &RetryJson{Attempts: -1, InitialBackoffInMilliseconds: 500, MaxBackoffInMilliseconds: 100}
+
+	c.Assert(input.Validate(), DeepEquals, &ValidationError{Problems: []string{
+		"warden_retries.attempts must not be negative",
+		"warden_retries.initial_backoff_in_milliseconds must not exceed warden_retries.max_backoff_in_milliseconds",
+	}})
+
+	input.WardenRetries = &RetryJson{Attempts: 3, InitialBackoffInMilliseconds: 500}
+	c.Assert(input.Validate(), IsNil)
+}
+
+func (s *ValidationSuite) TestBandwidthRateAndBurstGoTogether(c *C) {
+	input := s.validInput()
+	input.BandwidthRateInBytesPerSecond = 1000
+	c.Assert(input.Validate(), ErrorMatches, "bandwidth_burst_in_bytes is required when bandwidth_rate_in_bytes_per_second is set")
+
+	input = s.validInput()
+	input.BandwidthBurstInBytes = 1000
+	c.Assert(input.Validate(), ErrorMatches, "bandwidth_rate_in_bytes_per_second is required when bandwidth_burst_in_bytes is set")
+
+	input.BandwidthRateInBytesPerSecond = 1000
+	c.Assert(input.Validate(), IsNil)
+}
+
+func (s *ValidationSuite) TestSoftDiskLimitsMustNotExceedHardLimits(c *C) {
+	input := s.validInput()
+	input.DiskSoftLimitInBytes = 101
+	input.DiskInodeLimit = 10
+	input.DiskInodeSoftLimit = 11
+
+	c.Assert(input.Validate().(*ValidationError).Problems, DeepEquals, []string{
+		"disk_soft_limit_in_bytes must not exceed disk_limit_in_bytes",
+		"disk_inode_soft_limit must not exceed disk_inode_limit",
+	})
+
+	input.DiskSoftLimitInBytes = 100
+	input.DiskInodeLimit = 0
+	c.Assert(input.Validate(), IsNil)
+}
+
+func (s *ValidationSuite) TestInvalidEgressRules(c *C) {
+	input := s.validInput()
+	input.EgressRules = []*EgressRule{
+		{Network: "10.0.0.0/8", PortRange: "5432", Protocol: "tcp"},
+		{Network: "10.0.0.300", Protocol: "tcp"},
+		{Network: "10.0.0.1", PortRange: "80", Protocol: "icmp"},
+		{Network: "10.0.0.1", PortRange: "80-20", Protocol: "udp"},
+		{Network: "10.0.0.1", Protocol: "sctp"},
+	}
+
+	c.Assert(input.Validate().(*ValidationError).Problems, DeepEquals, []string{
+		`egress_rules[1]: invalid network "10.0.0.300", expected a CIDR or IPv4 address`,
+		`egress_rules[2]: port_range "80" requires protocol tcp or udp`,
+		`egress_rules[3]: invalid port_range "80-20", expected a port or first-last`,
+		`egress_rules[4]: invalid protocol "sctp", expected tcp, udp, icmp or all`,
+	})
+}
+
+func (s *ValidationSuite) TestAllowBoundServicesRequiresTaskEnvironment(c *C) {
+	input := s.validInput()
+	input.AllowBoundServices = true
+	input.Task = &TaskJson{Command: "true"}
+
+	c.Assert(input.Validate(), DeepEquals, &ValidationError{Problems: []string{
+		"allow_bound_services requires task.environment",
+	}})
+
+	input.Task.Environment = &parser.InputJSON{}
+	c.Assert(input.Validate(), IsNil)
+}
@@ -0,0 +1,311 @@
+package container
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	warden "github.com/cloudfoundry/gordon"
+	"github.com/golang/protobuf/proto"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var errNotConnected = errors.New("not connected to warden")
+
+type WardenError struct {
+	Message   string
+	Data      string
+	Backtrace []string
+}
+
+func (e *WardenError) Error() string {
+	return e.Message
+}
+
+type WardenConnection struct {
+	socketPath string
+
+	lock sync.Mutex
+	conn *wardenConn
+}
+
+func NewWardenConnection(socketPath string) *WardenConnection {
+	return &WardenConnection{socketPath: socketPath}
+}
+
+func (c *WardenConnection) Connect() error {
+	conn, err := dialWarden(c.socketPath)
+	if err != nil {
+		return err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.conn != nil {
+		c.conn.Close()
+	}
+	c.conn = conn
+	return nil
+}
+
+func (c *WardenConnection) CreateByRequest(request *warden.CreateRequest) (*warden.CreateResponse, error) {
+	response := &warden.CreateResponse{}
+	if err := c.roundTrip(warden.Message_Create, request, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (c *WardenConnection) Destroy(handle string) (*warden.DestroyResponse, error) {
+	response := &warden.DestroyResponse{}
+	if err := c.roundTrip(warden.Message_Destroy, &warden.DestroyRequest{Handle: &handle}, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (c *WardenConnection) Stop(handle string, background, kill bool) (*warden.StopResponse, error) {
+	request := &warden.StopRequest{Handle: &handle, Background: &background, Kill: &kill}
+	response := &warden.StopResponse{}
+	if err := c.roundTrip(warden.Message_Stop, request, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (c *WardenConnection) Info(handle string) (*warden.InfoResponse, error) {
+	response := &warden.InfoResponse{}
+	if err := c.roundTrip(warden.Message_Info, &warden.InfoRequest{Handle: &handle}, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (c *WardenConnection) ListByRequest(request *warden.ListRequest) (*warden.ListResponse, error) {
+	response := &warden.ListResponse{}
+	if err := c.roundTrip(warden.Message_List, request, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (c *WardenConnection) LimitDiskByRequest(request *warden.LimitDiskRequest) (*warden.LimitDiskResponse, error) {
+	response := &warden.LimitDiskResponse{}
+	if err := c.roundTrip(warden.Message_LimitDisk, request, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (c *WardenConnection) LimitMemory(handle string, limitInBytes uint64) (*warden.LimitMemoryResponse, error) {
+	request := &warden.LimitMemoryRequest{Handle: &handle, LimitInBytes: &limitInBytes}
+	response := &warden.LimitMemoryResponse{}
+	if err := c.roundTrip(warden.Message_LimitMemory, request, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (c *WardenConnection) LimitCpu(handle string, limitInShares uint64) (*warden.LimitCpuResponse, error) {
+	request := &warden.LimitCpuRequest{Handle: &handle, LimitInShares: &limitInShares}
+	response := &warden.LimitCpuResponse{}
+	if err := c.roundTrip(warden.Message_LimitCpu, request, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (c *WardenConnection) LimitBandwidth(handle string, rate, burst uint64) (*warden.LimitBandwidthResponse, error) {
+	request := &warden.LimitBandwidthRequest{Handle: &handle, Rate: &rate, Burst: &burst}
+	response := &warden.LimitBandwidthResponse{}
+	if err := c.roundTrip(warden.Message_LimitBandwidth, request, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (c *WardenConnection) NetIn(handle string) (*warden.NetInResponse, error) {
+	response := &warden.NetInResponse{}
+	if err := c.roundTrip(warden.Message_NetIn, &warden.NetInRequest{Handle: &handle}, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (c *WardenConnection) NetOutByRequest(request *warden.NetOutRequest) (*warden.NetOutResponse, error) {
+	response := &warden.NetOutResponse{}
+	if err := c.roundTrip(warden.Message_NetOut, request, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (c *WardenConnection) RunByRequest(request *warden.RunRequest) (*warden.RunResponse, error) {
+	response := &warden.RunResponse{}
+	if err := c.roundTrip(warden.Message_Run, request, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (c *WardenConnection) SpawnByRequest(request *warden.SpawnRequest) (*warden.SpawnResponse, error) {
+	response := &warden.SpawnResponse{}
+	if err := c.roundTrip(warden.Message_Spawn, request, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (c *WardenConnection) CopyIn(handle, srcPath, dstPath string) (*warden.CopyInResponse, error) {
+	request := &warden.CopyInRequest{Handle: &handle, SrcPath: &srcPath, DstPath: &dstPath}
+	response := &warden.CopyInResponse{}
+	if err := c.roundTrip(warden.Message_CopyIn, request, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (c *WardenConnection) CopyOut(handle, srcPath, dstPath, owner string) (*warden.CopyOutResponse, error) {
+	request := &warden.CopyOutRequest{Handle: &handle, SrcPath: &srcPath, DstPath: &dstPath, Owner: &owner}
+	response := &warden.CopyOutResponse{}
+	if err := c.roundTrip(warden.Message_CopyOut, request, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (c *WardenConnection) Link(handle string, jobId uint32) (*warden.LinkResponse, error) {
+	conn, err := dialWarden(c.socketPath)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	response := &warden.LinkResponse{}
+	if err := conn.roundTrip(warden.Message_Link, &warden.LinkRequest{Handle: &handle, JobId: &jobId}, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (c *WardenConnection) Stream(handle string, jobId uint32) (chan *warden.StreamResponse, error) {
+	conn, err := dialWarden(c.socketPath)
+	if err != nil {
+		return nil, err
+	}
+	err = conn.send(warden.Message_Stream, &warden.StreamRequest{Handle: &handle, JobId: &jobId})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	responses := make(chan *warden.StreamResponse)
+	go func() {
+		defer conn.Close()
+		defer close(responses)
+		for {
+			response := &warden.StreamResponse{}
+			if conn.receive(warden.Message_Stream, response) != nil {
+				return
+			}
+			responses <- response
+			if response.ExitStatus != nil {
+				return
+			}
+		}
+	}()
+	return responses, nil
+}
+
+func (c *WardenConnection) roundTrip(messageType warden.Message_Type, request, response proto.Message) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.conn == nil {
+		return errNotConnected
+	}
+
+	err := c.conn.roundTrip(messageType, request, response)
+	if _, wardenErr := err.(*WardenError); err != nil && !wardenErr {
+		c.conn.Close()
+		c.conn = nil
+	}
+	return err
+}
+
+type wardenConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func dialWarden(socketPath string) (*wardenConn, error) {
+	conn, err := net.Dial("unix", socketPath)
+	if err != nil {
+		return nil, err
+	}
+	return &wardenConn{Conn: conn, reader: bufio.NewReader(conn)}, nil
+}
+
+func (c *wardenConn) roundTrip(messageType warden.Message_Type, request, response proto.Message) error {
+	err := c.send(messageType, request)
+	if err != nil {
+		return err
+	}
+	return c.receive(messageType, response)
+}
+
+func (c *wardenConn) send(messageType warden.Message_Type, request proto.Message) error {
+	payload, err := proto.Marshal(request)
+	if err != nil {
+		return err
+	}
+	data, err := proto.Marshal(&warden.Message{Type: &messageType, Payload: payload})
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(c, "%d\r\n%s\r\n", len(data), data)
+	return err
+}
+
+func (c *wardenConn) receive(messageType warden.Message_Type, response proto.Message) error {
+	header, err := c.reader.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	length, err := strconv.Atoi(strings.TrimSpace(header))
+	if err != nil {
+		return fmt.Errorf("invalid message length %q from warden", strings.TrimSpace(header))
+	}
+	data := make([]byte, length+2)
+	_, err = io.ReadFull(c.reader, data)
+	if err != nil {
+		return err
+	}
+
+	message := &warden.Message{}
+	err = proto.Unmarshal(data[:length], message)
+	if err != nil {
+		return err
+	}
+
+	switch message.GetType() {
+	case warden.Message_Error:
+		errorResponse := &warden.ErrorResponse{}
+		err = proto.Unmarshal(message.GetPayload(), errorResponse)
+		if err != nil {
+			return err
+		}
+		return &WardenError{
+			Message:   errorResponse.GetMessage(),
+			Data:      errorResponse.GetData(),
+			Backtrace: errorResponse.GetBacktrace(),
+		}
+	case messageType:
+		return proto.Unmarshal(message.GetPayload(), response)
+	default:
+		return fmt.Errorf("unexpected %s response from warden to a %s request", message.GetType(), messageType)
+	}
+}
@@ -0,0 +1,162 @@
+package container
+
+import (
+	"bufio"
+	warden "github.com/cloudfoundry/gordon"
+	"io/ioutil"
+	. "launchpad.net/gocheck"
+	"net"
+	"os"
+	"path/filepath"
+)
+
+type WardenClientSuite struct {
+	directory  string
+	socketPath string
+	listener   net.Listener
+}
+
+func init() {
+	Suite(&WardenClientSuite{})
+}
+
+func (s *WardenClientSuite) SetUpTest(c *C) {
+	var err error
+	s.directory, err = ioutil.TempDir("", "warden_client")
+	c.Assert(err, IsNil)
+	s.socketPath = filepath.Join(s.directory, "warden.sock")
+	s.listener, err = net.Listen("unix", s.socketPath)
+	c.Assert(err, IsNil)
+}
+
+func (s *WardenClientSuite) TearDownTest(c *C) {
+	s.listener.Close()
+	os.RemoveAll(s.directory)
+}
+
+func (s *WardenClientSuite) serve(handle func(conn *wardenConn)) {
+	go func() {
+		for {
+			conn, err := s.listener.Accept()
+			if err != nil {
+				return
+			}
+			go handle(&wardenConn{Conn: conn, reader: bufio.NewReader(conn)})
+		}
+	}()
+}
+
+func (s *WardenClientSuite) TestRoundTripsRequests(c *C) {
+	handles := make(chan string, 1)
+	s.serve(func(conn *wardenConn) {
+		request := &warden.InfoRequest{}
+		if conn.receive(warden.Message_Info, request) == nil {
+			handles <- request.GetHandle()
+			state := "active"
+			conn.send(warden.Message_Info, &warden.InfoResponse{State: &state})
+		}
+	})
+
+	client := NewWardenConnection(s.socketPath)
+	c.Assert(client.Connect(), IsNil)
+	response, err := client.Info("container-1")
+
+	c.Assert(err, IsNil)
+	c.Assert(response.GetState(), Equals, "active")
+	c.Assert(<-handles, Equals, "container-1")
+}
+
+func (s *WardenClientSuite) TestErrorResponsesBecomeWardenErrors(c *C) {
+	s.serve(func(conn *wardenConn) {
+		for {
+			request := &warden.DestroyRequest{}
+			if conn.receive(warden.Message_Destroy, request) != nil {
+				return
+			}
+			message, data := "unknown handle", "container-1"
+			conn.send(warden.Message_Error, &warden.ErrorResponse{
+				Message:   &message,
+				Data:      &data,
+				Backtrace: []string{"destroy"},
+			})
+		}
+	})
+
+	client := NewWardenConnection(s.socketPath)
+	c.Assert(client.Connect(), IsNil)
+	_, err := client.Destroy("container-1")
+
+	c.Assert(err, DeepEquals, &WardenError{Message: "unknown handle", Data: "container-1", Backtrace: []string{"destroy"}})
+	c.Assert(isTransient(err), Equals, false)
+	_, err = client.Destroy("container-1")
+	c.Assert(err, FitsTypeOf, &WardenError{})
+}
+
+func (s *WardenClientSuite) TestRequestsFailUntilConnected(c *C) {
+	client := NewWardenConnection(s.socketPath)
+	_, err := client.Info("container-1")
+
+	c.Assert(err, Equals, errNotConnected)
+	c.Assert(isTransient(err), Equals, true)
+}
+
+func (s *WardenClientSuite) TestBrokenConnectionsNeedReconnecting(c *C) {
+	s.serve(func(conn *wardenConn) {
+		conn.Close()
+	})
+
+	client := NewWardenConnection(s.socketPath)
+	c.Assert(client.Connect(), IsNil)
+	_, err := client.Info("container-1")
+	c.Assert(err, NotNil)
+	c.Assert(isTransient(err), Equals, true)
+
+	_, err = client.Info("container-1")
+	c.Assert(err, Equals, errNotConnected)
+}
+
+func (s *WardenClientSuite) TestStreamRelaysOutputUntilTheJobExits(c *C) {
+	s.serve(func(conn *wardenConn) {
+		defer conn.Close()
+		request := &warden.StreamRequest{}
+		if conn.receive(warden.Message_Stream, request) != nil || request.GetJobId() != 7 {
+			return
+		}
+		stdout, stderr, data := "stdout", "stderr", "hello\n"
+		exitStatus := uint32(3)
+		conn.send(warden.Message_Stream, &warden.StreamResponse{Name: &stdout, Data: &data})
+		conn.send(warden.Message_Stream, &warden.StreamResponse{Name: &stderr, Data: &data})
+		conn.send(warden.Message_Stream, &warden.StreamResponse{ExitStatus: &exitStatus})
+	})
+
+	client := NewWardenConnection(s.socketPath)
+	responses, err := client.Stream("container-1", 7)
+	c.Assert(err, IsNil)
+
+	var received []*warden.StreamResponse
+	for response := range responses {
+		received = append(received, response)
+	}
+	c.Assert(received, HasLen, 3)
+	c.Assert(received[0].GetName(), Equals, "stdout")
+	c.Assert(received[1].GetName(), Equals, "stderr")
+	c.Assert(received[1].GetData(), Equals, "hello\n")
+	c.Assert(received[2].GetExitStatus(), Equals, uint32(3))
+}
+
+func (s *WardenClientSuite) TestLinkUsesItsOwnConnection(c *C) {
+	s.serve(func(conn *wardenConn) {
+		defer conn.Close()
+		request := &warden.LinkRequest{}
+		if conn.receive(warden.Message_Link, request) == nil {
+			exitStatus := request.GetJobId()
+			conn.send(warden.Message_Link, &warden.LinkResponse{ExitStatus: &exitStatus})
+		}
+	})
+
+	client := NewWardenConnection(s.socketPath)
+	response, err := client.Link("container-1", 42)
+
+	c.Assert(err, IsNil)
+	c.Assert(response.GetExitStatus(), Equals, uint32(42))
+}
@@ -0,0 +1,125 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+)
+
+type ServiceEndpoint struct {
+	Host string
+	Port int
+}
+
+type ServiceEndpointGenerator struct {
+	services           []InputServiceJSON
+	defaultSchemePorts map[string]int
+}
+
+func NewServiceEndpointGenerator(services []InputServiceJSON) *ServiceEndpointGenerator {
+	return &ServiceEndpointGenerator{
+		services: services,
+		defaultSchemePorts: map[string]int{
+			"http": 80, "https": 443,
+			"mysql": 3306, "mysql2": 3306,
+			"postgres": 5432, "postgresql": 5432,
+			"redis": 6379, "mongodb": 27017,
+			"amqp": 5672, "amqps": 5671,
+		},
+	}
+}
+
+func (generator *ServiceEndpointGenerator) Generate() ([]ServiceEndpoint, error) {
+	endpoints := make([]ServiceEndpoint, 0)
+	seen := make(map[ServiceEndpoint]bool)
+
+	for _, service := range generator.services {
+		serviceEndpoints, err := generator.endpointsForService(service)
+		if err != nil {
+			return nil, err
+		}
+		for _, endpoint := range serviceEndpoints {
+			if !seen[endpoint] {
+				seen[endpoint] = true
+				endpoints = append(endpoints, endpoint)
+			}
+		}
+	}
+	return endpoints, nil
+}
+
+func (generator *ServiceEndpointGenerator) endpointsForService(service InputServiceJSON) ([]ServiceEndpoint, error) {
+	var endpoints []ServiceEndpoint
+
+	if uri, ok := service.Credentials["uri"].(string); ok && uri != "" {
+		endpoint, err := generator.endpointFromURI(uri)
+		if err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+
+	host, ok := service.Credentials["host"].(string)
+	if !ok || host == "" {
+		host, ok = service.Credentials["hostname"].(string)
+	}
+	if ok && host != "" {
+		port, err := credentialPort(service.Credentials["port"])
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Invalid port for service \"%s\": %s", service.Name, err))
+		}
+		endpoints = append(endpoints, ServiceEndpoint{Host: host, Port: port})
+	}
+
+	return endpoints, nil
+}
+
+func (generator *ServiceEndpointGenerator) endpointFromURI(uri string) (ServiceEndpoint, error) {
+	parsedURI, err := url.Parse(uri)
+	if err != nil || parsedURI.Host == "" {
+		return ServiceEndpoint{}, errors.New(fmt.Sprintf("Invalid service URI \"%s\"", sanitizeURI(uri)))
+	}
+
+	host, portString, err := net.SplitHostPort(parsedURI.Host)
+	if err != nil {
+		port, ok := generator.defaultSchemePorts[parsedURI.Scheme]
+		if !ok {
+			return ServiceEndpoint{}, errors.New(fmt.Sprintf("Unable to determine port for service URI \"%s\"", sanitizeURI(uri)))
+		}
+		return ServiceEndpoint{Host: parsedURI.Host, Port: port}, nil
+	}
+
+	port, err := credentialPort(portString)
+	if err != nil {
+		return ServiceEndpoint{}, errors.New(fmt.Sprintf("Invalid service URI \"%s\"", sanitizeURI(uri)))
+	}
+	return ServiceEndpoint{Host: host, Port: port}, nil
+}
+
+func credentialPort(value interface{}) (int, error) {
+	var port int
+	switch value := value.(type) {
+	case float64:
+		port = int(value)
+		if float64(port) != value {
+			return 0, errors.New(fmt.Sprintf("%v is not a whole number", value))
+		}
+	case string:
+		var err error
+		port, err = strconv.Atoi(value)
+		if err != nil {
+			return 0, errors.New(fmt.Sprintf("\"%s\" is not a number", value))
+		}
+	case nil:
+		return 0, errors.New("missing port")
+	default:
+		return 0, errors.New(fmt.Sprintf("%v is not a number", value))
+	}
+
+	if port < 1 || port > 65535 {
+		return 0, errors.New(fmt.Sprintf("%d is out of range", port))
+	}
+	return port, nil
+}